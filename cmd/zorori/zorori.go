@@ -1,48 +1,243 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"math/rand"
 	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/dns/server"
+	"github.com/nna774/zorori/dns/zone"
+	zresolver "github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/ddr"
 	"github.com/nna774/zorori/resolver/doh"
+	"github.com/nna774/zorori/resolver/hosts"
+	"github.com/nna774/zorori/resolver/multi"
+	"github.com/nna774/zorori/resolver/resolvconf"
+	"github.com/nna774/zorori/resolver/router"
 	"github.com/nna774/zorori/resolver/udp"
 )
 
-func init() {
-	rand.Seed(42)
-}
-
 var (
-	mode         = flag.String("mode", "doh", "resolve mode")
+	mode         = flag.String("mode", "doh", "resolve mode (doh, udp, ddr, router, system)")
 	stub         = flag.Bool("stub", true, "stub resolve")
-	fullResolver = flag.String("fullresolver", "8.8.8.8", "ip addr of full resolver")
-	dohServer    = flag.String("doh", "https://dns.google/dns-query", "doh server")
-	queryType    = flag.String("type", "A", "query type")
+	fullResolver = flag.String("fullresolver", "8.8.8.8", "ip addrs of full resolvers, comma separated")
+	dohServer    = flag.String("doh", "https://dns.google/dns-query", "doh servers, comma separated")
+	strategy     = flag.String("strategy", "failover", "strategy for multiple upstreams (failover, roundrobin, fastest, race)")
+	bootstrap    = flag.String("bootstrap", "", "ip addr of resolver to resolve doh server name")
+	queryType    = flag.String("type", "A", "query type (A, AAAA, IP, SVCB, HTTPS, TYPEnnn, ...)")
+	short        = flag.Bool("short", false, "show only typed results instead of the whole message")
+	jsonOutput   = flag.Bool("json", false, "show the whole message in JSON (RFC 8427)")
+	routes       = flag.String("routes", "routes.conf", "route config file for router mode")
+	resolvConf   = flag.String("resolvconf", "/etc/resolv.conf", "resolv.conf for system mode")
+	hostsFile    = flag.String("hosts", "/etc/hosts", "hosts file for system mode")
+	qmin         = flag.Bool("qmin", true, "QNAME minimisation on full resolve")
+	reverse      = flag.String("x", "", "ip addr for reverse lookup")
+	listen       = flag.String("listen", "127.0.0.1:53", "addr to listen on serve")
+	dotListen    = flag.String("dot", "", "addr to listen DoT on serve, e.g. :853")
+	certFile     = flag.String("cert", "", "certificate file for DoT")
+	keyFile      = flag.String("key", "", "key file for DoT")
+	zones        = flag.String("zones", "", "zone files to serve authoritatively on serve, comma separated [origin=]path")
 )
 
+func newResolver() (zresolver.Resolver, error) {
+	upstreams := []zresolver.Resolver{}
+	switch *mode {
+	case "doh":
+		opts := []doh.Option{}
+		if *bootstrap != "" {
+			opts = append(opts, doh.WithBootstrapResolver(udp.NewUDPStubResolver(net.ParseIP(*bootstrap))))
+		}
+		for _, u := range strings.Split(*dohServer, ",") {
+			upstreams = append(upstreams, doh.NewDoHResolver(u, opts...))
+		}
+	case "ddr":
+		for _, ip := range strings.Split(*fullResolver, ",") {
+			r, err := ddr.NewResolver(net.ParseIP(ip))
+			if err != nil {
+				return nil, err
+			}
+			upstreams = append(upstreams, r)
+		}
+	case "udp":
+		if !*stub {
+			opts := []udp.Option{}
+			if !*qmin {
+				opts = append(opts, udp.DisableQNAMEMinimisation())
+			}
+			return udp.NewUDPFullResolver(opts...), nil
+		}
+		for _, ip := range strings.Split(*fullResolver, ",") {
+			upstreams = append(upstreams, udp.NewUDPStubResolver(net.ParseIP(ip)))
+		}
+	case "router":
+		return router.LoadFile(*routes)
+	case "system":
+		stub, err := resolvconf.NewResolver(*resolvConf)
+		if err != nil {
+			return nil, err
+		}
+		return hosts.New(*hostsFile, stub), nil
+	default:
+		return nil, fmt.Errorf("unknown mode: %v", *mode)
+	}
+	if len(upstreams) == 1 {
+		return upstreams[0], nil
+	}
+	s, err := multi.ParseStrategy(*strategy)
+	if err != nil {
+		return nil, err
+	}
+	return multi.New(s, upstreams), nil
+}
+
+func loadZones() (*zone.Authority, error) {
+	loaded := []*zone.Zone{}
+	if *zones == "" {
+		return zone.NewAuthority(), nil
+	}
+	for _, spec := range strings.Split(*zones, ",") {
+		origin, path := "", spec
+		if i := strings.IndexByte(spec, '='); i >= 0 {
+			origin, path = spec[:i], spec[i+1:]
+		}
+		z, err := zone.Load(path, origin)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+		loaded = append(loaded, z)
+	}
+	return zone.NewAuthority(loaded...), nil
+}
+
+// serve answers zones and forwards others on *listen to resolver until signaled.
+func serve(resolver zresolver.Resolver) error {
+	auth, err := loadZones()
+	if err != nil {
+		return err
+	}
+	s := server.New(server.HandlerFunc(func(q dns.Query) (dns.Answer, error) {
+		if _, ok := auth.Zone(q.Question.Name()); ok {
+			return auth.ServeDNS(q)
+		}
+		return zresolver.Forward(resolver, q), nil
+	}))
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	}()
+	errc := make(chan error, 2)
+	go func() { errc <- s.ListenAndServe(*listen) }()
+	if *dotListen != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			s.Shutdown(context.Background())
+			return err
+		}
+		go func() { errc <- s.ListenAndServeTLS(*dotListen, &tls.Config{Certificates: []tls.Certificate{cert}}) }()
+	}
+	if err := <-errc; err != server.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
 func main() {
-	flag.Parse()
+	// zorori serve [flags] で forwarding proxy になる。
+	args := os.Args[1:]
+	serving := len(args) >= 1 && args[0] == "serve"
+	if serving {
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
 	name := "www.jprs.co.jp"
-	args := flag.Args()
+	args = flag.Args()
 	if len(args) >= 1 {
 		name = args[0]
 	}
 
-	var resolver resolver.Resolver
-	if *mode == "doh" {
-		resolver = doh.NewDoHResolver(*dohServer)
+	resolver, err := newResolver()
+	if err != nil {
+		fmt.Printf("bie %v", err)
+		return
 	}
-	if *mode == "udp" {
-		if *stub {
-			resolver = udp.NewUDPStubResolver(net.ParseIP(*fullResolver))
-		} else {
-			resolver = udp.NewUDPFullResolver()
+
+	if serving {
+		if err := serve(resolver); err != nil {
+			fmt.Printf("bie %v", err)
 		}
+		return
 	}
 
+	if *reverse != "" {
+		ip := net.ParseIP(*reverse)
+		if ip == nil {
+			fmt.Printf("bie invalid ip: %v\n", *reverse)
+			return
+		}
+		if *short && !*jsonOutput {
+			showPTR(resolver, ip)
+			return
+		}
+		show(resolver, dns.ReverseName(ip), dns.PTR)
+		return
+	}
+
+	if (*short && !*jsonOutput) || *queryType == "IP" {
+		showShort(resolver, name)
+		return
+	}
+	t, ok := dns.ParseQueryType(*queryType)
+	if !ok {
+		fmt.Printf("unknown query type: %v\n", *queryType)
+		return
+	}
+	show(resolver, name, t)
+}
+
+// show prints the whole answer in dig style or JSON.
+func show(resolver zresolver.Resolver, name string, t dns.QueryType) {
+	ans, err := resolver.Resolve(name, t)
+	if err != nil {
+		fmt.Printf("bie %v", err)
+		return
+	}
+	if *jsonOutput {
+		b, err := json.Marshal(ans)
+		if err != nil {
+			fmt.Printf("bie %v", err)
+			return
+		}
+		fmt.Println(string(b))
+		return
+	}
+	fmt.Print(ans)
+}
+
+func showPTR(resolver zresolver.Resolver, ip net.IP) {
+	res, err := resolver.ReverseResolve(ip)
+	if err != nil {
+		fmt.Printf("bie %v", err)
+		return
+	}
+	for _, n := range res.Names() {
+		fmt.Printf("PTR: %v\n", n)
+	}
+}
+
+func showShort(resolver zresolver.Resolver, name string) {
 	switch *queryType {
 	case "A":
 		res, err := resolver.AResolve(name)
@@ -50,14 +245,45 @@ func main() {
 			fmt.Printf("bie %v", err)
 			return
 		}
-		fmt.Printf("A: %v\n", res.IP())
+		for _, r := range res.Records() {
+			fmt.Printf("A: %v (TTL: %v)\n", r.IP, r.TTL)
+		}
+	case "AAAA":
+		res, err := resolver.AAAAResolve(name)
+		if err != nil {
+			fmt.Printf("bie %v", err)
+			return
+		}
+		for _, r := range res.Records() {
+			fmt.Printf("AAAA: %v (TTL: %v)\n", r.IP, r.TTL)
+		}
+	case "IP":
+		ips, err := zresolver.LookupIP(resolver, name)
+		if err != nil {
+			fmt.Printf("bie %v", err)
+			return
+		}
+		for _, ip := range ips {
+			fmt.Printf("IP: %v\n", ip)
+		}
 	case "SVCB":
-		res, err := resolver.SVCBResolve()
+		res, err := resolver.SVCBResolve(name)
 		if err != nil {
 			fmt.Printf("bie %v", err)
 			return
 		}
-		fmt.Printf("SVCB: %v %v %v\n", res.Priority, res.Target, res.Params)
+		for _, r := range res {
+			fmt.Printf("SVCB: %v %v %v\n", r.Priority, r.Target, r.Params)
+		}
+	case "HTTPS":
+		res, err := resolver.HTTPSResolve(name)
+		if err != nil {
+			fmt.Printf("bie %v", err)
+			return
+		}
+		for _, r := range res {
+			fmt.Printf("HTTPS: %v %v %v\n", r.Priority, r.Target, r.Params)
+		}
 	default:
 		fmt.Printf("unknown query type: %v\n", *queryType)
 	}
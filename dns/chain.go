@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrCNAMELoop is error for looping CNAME chain
+var ErrCNAMELoop = errors.New("CNAME loop")
+
+// Chase follows CNAME chain from name in rrs regardless of their order,
+// and returns the canonical name and records of t owned by it
+func Chase(rrs []ResourceRecord, name string, t QueryType) (string, []ResourceRecord, error) {
+	graph := map[string]string{}
+	dnames := []ResourceRecord{}
+	for _, rr := range rrs {
+		switch rr.T {
+		case CNAME:
+			target, _ := rr.CNAMETO()
+			graph[Normalize(rr.Name)] = target
+		case DNAME:
+			dnames = append(dnames, rr)
+		}
+	}
+	visited := map[string]bool{}
+	cur := name
+	for t != CNAME {
+		key := Normalize(cur)
+		next, ok := graph[key]
+		if !ok {
+			cname, synthesized := synthesizeFrom(dnames, cur)
+			if !synthesized {
+				break
+			}
+			next, _ = cname.CNAMETO()
+		}
+		if visited[key] {
+			return cur, nil, ErrCNAMELoop
+		}
+		visited[key] = true
+		cur = next
+	}
+	records := []ResourceRecord{}
+	for _, rr := range rrs {
+		if rr.T == t && Same(rr.Name, cur) {
+			records = append(records, rr)
+		}
+	}
+	return cur, records, nil
+}
+
+// SynthesizeCNAME makes CNAME for qname from dname covering it. see RFC 6672 section 2.2
+func SynthesizeCNAME(dname ResourceRecord, qname string) (ResourceRecord, bool) {
+	if dname.T != DNAME || Same(dname.Name, qname) || !IsSubDomain(qname, dname.Name) {
+		return ResourceRecord{}, false
+	}
+	target, err := dname.DNAMETO()
+	if err != nil {
+		return ResourceRecord{}, false
+	}
+	labels := SplitLabels(qname)
+	prefix := labels[:len(labels)-len(SplitLabels(dname.Name))]
+	synthesized := Fqdn(strings.Join(append(prefix, SplitLabels(target)...), "."))
+	if len(synthesized) > 255 {
+		// YXDOMAIN
+		return ResourceRecord{}, false
+	}
+	return NewResourceRecord(Fqdn(qname), CNAME, dname.Class, dname.TTL, PackName(synthesized)), true
+}
+
+// synthesizeFrom uses the closest dname covering qname.
+func synthesizeFrom(dnames []ResourceRecord, qname string) (ResourceRecord, bool) {
+	var closest *ResourceRecord
+	for i := range dnames {
+		if Same(dnames[i].Name, qname) || !IsSubDomain(qname, dnames[i].Name) {
+			continue
+		}
+		if closest == nil || len(SplitLabels(dnames[i].Name)) > len(SplitLabels(closest.Name)) {
+			closest = &dnames[i]
+		}
+	}
+	if closest == nil {
+		return ResourceRecord{}, false
+	}
+	return SynthesizeCNAME(*closest, qname)
+}
@@ -0,0 +1,78 @@
+package dns
+
+import (
+	"net"
+	"testing"
+)
+
+func TestChase(t *testing.T) {
+	a := NewResourceRecord("c.example.com.", A, IN, 300, []byte{192, 0, 2, 1})
+	ab := NewResourceRecord("a.example.com.", CNAME, IN, 300, PackName("b.example.com."))
+	bc := NewResourceRecord("b.example.com.", CNAME, IN, 300, PackName("c.example.com."))
+	cases := []struct {
+		name string
+		rrs  []ResourceRecord
+	}{
+		{"ordered", []ResourceRecord{ab, bc, a}},
+		{"reversed", []ResourceRecord{a, bc, ab}},
+		{"shuffled", []ResourceRecord{bc, a, ab}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			name, rrs, err := Chase(c.rrs, "A.example.com", A)
+			if err != nil {
+				t.Fatalf("err should be nil: %v", err)
+			}
+			if !Same(name, "c.example.com") {
+				t.Fatalf("expected: c.example.com, but got %v", name)
+			}
+			if len(rrs) != 1 {
+				t.Fatalf("expected 1 record, but got %v", rrs)
+			}
+			ip, _ := rrs[0].IP()
+			if !ip.Equal(net.ParseIP("192.0.2.1")) {
+				t.Fatalf("expected: 192.0.2.1, but got %v", ip)
+			}
+		})
+	}
+}
+
+func TestChaseLoop(t *testing.T) {
+	rrs := []ResourceRecord{
+		NewResourceRecord("a.example.com.", CNAME, IN, 300, PackName("b.example.com.")),
+		NewResourceRecord("b.example.com.", CNAME, IN, 300, PackName("a.example.com.")),
+	}
+	_, _, err := Chase(rrs, "a.example.com.", A)
+	if err != ErrCNAMELoop {
+		t.Fatalf("expected: %v, but got %v", ErrCNAMELoop, err)
+	}
+}
+
+func TestChaseDNAME(t *testing.T) {
+	rrs := []ResourceRecord{
+		NewResourceRecord("www.new.example.", A, IN, 300, []byte{192, 0, 2, 1}),
+		NewResourceRecord("old.example.", DNAME, IN, 300, PackName("new.example.")),
+	}
+	name, records, err := Chase(rrs, "www.old.example.", A)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if !Same(name, "www.new.example.") || len(records) != 1 {
+		t.Fatalf("expected www.new.example. with 1 record, but got %v %v", name, records)
+	}
+}
+
+func TestSynthesizeCNAME(t *testing.T) {
+	dname := NewResourceRecord("old.example.", DNAME, IN, 300, PackName("new.example."))
+	cname, ok := SynthesizeCNAME(dname, "a.b.OLD.example")
+	if !ok {
+		t.Fatalf("should be synthesized")
+	}
+	target, _ := cname.CNAMETO()
+	if target != "a.b.new.example." || cname.Name != "a.b.OLD.example." || cname.TTL != 300 {
+		t.Fatalf("unexpected cname: %v", cname)
+	}
+	if _, ok := SynthesizeCNAME(dname, "old.example."); ok {
+		t.Fatalf("owner itself should not be synthesized")
+	}
+}
@@ -0,0 +1,46 @@
+package dns
+
+import "encoding/binary"
+
+// EDNS0OptionCode is code of EDNS0 option
+type EDNS0OptionCode uint16
+
+const (
+	// EDNS0TCPKeepalive is edns-tcp-keepalive option. see RFC 7828
+	EDNS0TCPKeepalive EDNS0OptionCode = 11
+)
+
+// PackEDNS0Option returns option in wire format to put in OPT rdata
+func PackEDNS0Option(code EDNS0OptionCode, data []byte) []byte {
+	b := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	binary.BigEndian.PutUint16(b[2:], uint16(len(data)))
+	copy(b[4:], data)
+	return b
+}
+
+// findEDNS0Option looks up code in OPT rdata.
+func findEDNS0Option(options []byte, code EDNS0OptionCode) ([]byte, bool) {
+	for len(options) >= 4 {
+		c := EDNS0OptionCode(binary.BigEndian.Uint16(options))
+		l := int(binary.BigEndian.Uint16(options[2:]))
+		if len(options) < 4+l {
+			return nil, false
+		}
+		if c == code {
+			return options[4 : 4+l], true
+		}
+		options = options[4+l:]
+	}
+	return nil, false
+}
+
+// SetEDNS0Option adds option to OPT record, EDNS0 must be set before
+func (q *Query) SetEDNS0Option(code EDNS0OptionCode, data []byte) {
+	q.options = append(q.options, PackEDNS0Option(code, data)...)
+}
+
+// EDNS0Option returns data of option code in OPT record
+func (q *Query) EDNS0Option(code EDNS0OptionCode) ([]byte, bool) {
+	return findEDNS0Option(q.options, code)
+}
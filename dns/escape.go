@@ -0,0 +1,104 @@
+package dns
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Escape escapes b as a token of master file, it is decoded by Unescape. see RFC 1035 5.1
+func Escape(b []byte) string {
+	return escape(b, false)
+}
+
+// escape escapes b, in quotes only '"' and '\' are special and spaces are kept.
+func escape(b []byte, quoted bool) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch {
+		case quoted && c == ' ':
+			sb.WriteByte(c)
+		case c < 0x21 || c > 0x7e:
+			fmt.Fprintf(&sb, "\\%03d", c)
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case !quoted && (c == ';' || c == '(' || c == ')' || c == '@' || c == '$'):
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// Unescape decodes \X and \DDD in s. see RFC 1035 5.1
+func Unescape(s string) ([]byte, error) {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return nil, fmt.Errorf("dangling backslash in %v", s)
+		}
+		if s[i+1] < '0' || s[i+1] > '9' {
+			b = append(b, s[i+1])
+			i++
+			continue
+		}
+		if i+3 >= len(s) {
+			return nil, fmt.Errorf("bad \\DDD escape in %v", s)
+		}
+		n, err := strconv.ParseUint(s[i+1:i+4], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("bad \\DDD escape in %v", s)
+		}
+		b = append(b, byte(n))
+		i += 3
+	}
+	return b, nil
+}
+
+// unescapeName decodes escaped name s label by label, and checks its length. see RFC 1035 2.3.4
+func unescapeName(s string) (string, error) {
+	raws := []string{}
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '.':
+			raws = append(raws, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		raws = append(raws, s[start:])
+	}
+	labels := make([]string, len(raws))
+	for i, raw := range raws {
+		label, err := Unescape(raw)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case len(label) == 0:
+			return "", fmt.Errorf("empty label in %v", s)
+		case len(label) > 63:
+			return "", fmt.Errorf("label too long in %v", s)
+		case strings.IndexByte(string(label), '.') >= 0:
+			// 名前を文字列で持っているので、ラベル中の '.' は表せない。
+			return "", fmt.Errorf("dot in label is not supported: %v", s)
+		}
+		labels[i] = string(label)
+	}
+	name := strings.Join(labels, ".") + "."
+	// wire format ではルートの 0 が 1 オクテット増える。
+	if len(name)+1 > 255 {
+		return "", fmt.Errorf("name too long: %v", s)
+	}
+	return name, nil
+}
@@ -0,0 +1,63 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	raw := []byte("a b\"\\;()@$\x00\xff.")
+	escaped := Escape(raw)
+	if escaped != `a\032b\"\\\;\(\)\@\$\000\255.` {
+		t.Fatalf("unexpected escape: %v", escaped)
+	}
+	b, err := Unescape(escaped)
+	if err != nil || string(b) != string(raw) {
+		t.Fatalf("round trip failed: %q, %v", b, err)
+	}
+	if quoted := escape(raw, true); quoted != `a b\"\\;()@$\000\255.` {
+		t.Fatalf("unexpected quote: %v", quoted)
+	}
+
+	for _, s := range []string{`a\`, `\25`, `\256`, `\2a5`} {
+		if _, err := Unescape(s); err == nil {
+			t.Fatalf("%v should be error", s)
+		}
+	}
+}
+
+func TestUnescapeName(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"example.com", "example.com."},
+		{"example.com.", "example.com."},
+		{`\101xample.com.`, "example.com."},
+		{`a\032b.example.`, "a b.example."},
+		{strings.Repeat("a", 63) + ".", strings.Repeat("a", 63) + "."},
+	}
+	for _, c := range cases {
+		name, err := unescapeName(c.name)
+		if err != nil {
+			t.Fatalf("err should be nil: %v", err)
+		}
+		if name != c.expected {
+			t.Fatalf("expected: %v, but got %v", c.expected, name)
+		}
+	}
+
+	for _, s := range []string{
+		"a..example.",
+		`a\.b.example.`,
+		`a\046b.example.`,
+		strings.Repeat("a", 64) + ".",
+		// 63 * 4 + 4 = 256 オクテット
+		strings.Repeat(strings.Repeat("a", 63)+".", 4),
+		`a\`,
+	} {
+		if _, err := unescapeName(s); err == nil {
+			t.Fatalf("%v should be error", s)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package dns
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// jsonMessage is the message object. see RFC 8427 2.1
+type jsonMessage struct {
+	ID            uint16            `json:"ID"`
+	QR            bool              `json:"QR"`
+	Opcode        int               `json:"Opcode"`
+	AA            bool              `json:"AA"`
+	TC            bool              `json:"TC"`
+	RD            bool              `json:"RD"`
+	RA            bool              `json:"RA"`
+	AD            bool              `json:"AD"`
+	CD            bool              `json:"CD"`
+	RCODE         int               `json:"RCODE"`
+	QDCOUNT       int               `json:"QDCOUNT"`
+	ANCOUNT       int               `json:"ANCOUNT"`
+	NSCOUNT       int               `json:"NSCOUNT"`
+	ARCOUNT       int               `json:"ARCOUNT"`
+	QNAME         string            `json:"QNAME,omitempty"`
+	QTYPE         uint16            `json:"QTYPE,omitempty"`
+	QTYPEname     string            `json:"QTYPEname,omitempty"`
+	QCLASS        uint16            `json:"QCLASS,omitempty"`
+	QCLASSname    string            `json:"QCLASSname,omitempty"`
+	QuestionRRs   []json.RawMessage `json:"questionRRs,omitempty"`
+	AnswerRRs     []json.RawMessage `json:"answerRRs,omitempty"`
+	AuthorityRRs  []json.RawMessage `json:"authorityRRs,omitempty"`
+	AdditionalRRs []json.RawMessage `json:"additionalRRs,omitempty"`
+}
+
+// MarshalJSON encodes a with RFC 8427 member names
+func (a Answer) MarshalJSON() ([]byte, error) {
+	h := a.Header
+	m := jsonMessage{
+		ID:      h.ID(),
+		QR:      h.qr(),
+		Opcode:  h.opCode(),
+		AA:      h.AA(),
+		TC:      h.TC(),
+		RD:      h.rd(),
+		RA:      h.ra(),
+		AD:      h.ad(),
+		CD:      h.cd(),
+		RCODE:   h.RCode(),
+		QDCOUNT: len(a.Questions),
+		ANCOUNT: len(a.Answers),
+		NSCOUNT: len(a.Authorities),
+		ARCOUNT: len(a.Additionals),
+	}
+	if len(a.Questions) == 1 {
+		// question が1つなら QNAME などで表す。 see RFC 8427 2.1
+		q := a.Questions[0]
+		m.QNAME = presentName(Fqdn(q.name))
+		m.QTYPE = uint16(q.t)
+		m.QTYPEname = q.t.String()
+		m.QCLASS = IN
+		m.QCLASSname = Class(IN).String()
+	} else {
+		for _, q := range a.Questions {
+			b, err := json.Marshal(map[string]interface{}{
+				"NAME":  presentName(Fqdn(q.name)),
+				"TYPE":  uint16(q.t),
+				"CLASS": IN,
+			})
+			if err != nil {
+				return nil, err
+			}
+			m.QuestionRRs = append(m.QuestionRRs, b)
+		}
+	}
+	var err error
+	if m.AnswerRRs, err = marshalRRs(a.Answers); err != nil {
+		return nil, err
+	}
+	if m.AuthorityRRs, err = marshalRRs(a.Authorities); err != nil {
+		return nil, err
+	}
+	if m.AdditionalRRs, err = marshalRRs(a.Additionals); err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func marshalRRs(rrs []ResourceRecord) ([]json.RawMessage, error) {
+	result := []json.RawMessage{}
+	for _, rr := range rrs {
+		b, err := json.Marshal(rr)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+// MarshalJSON encodes r as RR object. see RFC 8427 2.2, 2.3
+func (r ResourceRecord) MarshalJSON() ([]byte, error) {
+	rdata, err := r.rdata()
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{
+		"NAME":     presentName(r.Name),
+		"TYPE":     uint16(r.T),
+		"CLASS":    uint16(r.Class),
+		"TTL":      r.TTL,
+		"RDLENGTH": len(rdata),
+		"rdataHEX": hex.EncodeToString(rdata),
+	}
+	if name := r.T.String(); knownType(r.T) {
+		obj["TYPEname"] = name
+		if r.T != OPT {
+			obj["rdata"+name] = r.ShowRdata(r.T)
+		}
+	}
+	if r.T != OPT && r.Class == IN {
+		// OPT の CLASS は udp payload size。
+		obj["CLASSname"] = r.Class.String()
+	}
+	return json.Marshal(obj)
+}
+
+func knownType(t QueryType) bool {
+	for _, known := range knownTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalJSON decodes RFC 8427 message object into a
+func (a *Answer) UnmarshalJSON(b []byte) error {
+	var m jsonMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	result := Answer{}
+	h := &result.Header
+	h.setID(m.ID)
+	h.setQR(m.QR)
+	h.setOpCode(m.Opcode)
+	h.SetAA(m.AA)
+	h.setTC(m.TC)
+	h.setRD(m.RD)
+	h.SetRA(m.RA)
+	h.setAD(m.AD)
+	h.setCD(m.CD)
+	h.SetRCode(m.RCODE)
+
+	if m.QNAME != "" {
+		name, err := parsePresentName(m.QNAME)
+		if err != nil {
+			return err
+		}
+		result.Questions = append(result.Questions, NewQuestion(name, QueryType(m.QTYPE)))
+	}
+	for _, raw := range m.QuestionRRs {
+		var q struct {
+			NAME string
+			TYPE uint16
+		}
+		if err := json.Unmarshal(raw, &q); err != nil {
+			return err
+		}
+		name, err := parsePresentName(q.NAME)
+		if err != nil {
+			return err
+		}
+		result.Questions = append(result.Questions, NewQuestion(name, QueryType(q.TYPE)))
+	}
+	var err error
+	if result.Answers, err = unmarshalRRs(m.AnswerRRs); err != nil {
+		return err
+	}
+	if result.Authorities, err = unmarshalRRs(m.AuthorityRRs); err != nil {
+		return err
+	}
+	if result.Additionals, err = unmarshalRRs(m.AdditionalRRs); err != nil {
+		return err
+	}
+	h.setQDCount(uint16(len(result.Questions)))
+	h.setANCount(uint16(len(result.Answers)))
+	h.setNSCount(uint16(len(result.Authorities)))
+	h.setARCount(uint16(len(result.Additionals)))
+	*a = result
+	return nil
+}
+
+func unmarshalRRs(raws []json.RawMessage) ([]ResourceRecord, error) {
+	rrs := []ResourceRecord{}
+	for _, raw := range raws {
+		var rr ResourceRecord
+		if err := json.Unmarshal(raw, &rr); err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// UnmarshalJSON decodes RR object into r, rdata is taken from rdataHEX or rdata of simple types
+func (r *ResourceRecord) UnmarshalJSON(b []byte) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	var rr struct {
+		NAME     string
+		TYPE     uint16
+		CLASS    uint16
+		TTL      uint32
+		RdataHEX *string `json:"rdataHEX"`
+	}
+	if err := json.Unmarshal(b, &rr); err != nil {
+		return err
+	}
+	name, err := parsePresentName(rr.NAME)
+	if err != nil {
+		return err
+	}
+	t := QueryType(rr.TYPE)
+	var rdata []byte
+	if rr.RdataHEX != nil {
+		rdata, err = hex.DecodeString(*rr.RdataHEX)
+		if err != nil {
+			return fmt.Errorf("bad rdataHEX: %v", err)
+		}
+	} else if raw, ok := obj["rdata"+t.String()]; ok {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		if rdata, err = parseSimpleRdata(t, s); err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("no rdata for %v", t)
+	}
+	*r = NewResourceRecord(name, t, Class(rr.CLASS), rr.TTL, rdata)
+	return nil
+}
+
+// parseSimpleRdata parses presentation format of types which have only an address or a name.
+func parseSimpleRdata(t QueryType, s string) ([]byte, error) {
+	switch t {
+	case A, AAAA:
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("bad address: %v", s)
+		}
+		if t == A {
+			if ip = ip.To4(); ip == nil {
+				return nil, fmt.Errorf("not IPv4 address: %v", s)
+			}
+			return []byte(ip), nil
+		}
+		return []byte(ip.To16()), nil
+	case CNAME, NS, DNAME, PTR:
+		name, err := parsePresentName(s)
+		if err != nil {
+			return nil, err
+		}
+		return PackName(name), nil
+	case MX:
+		var pref uint16
+		var target string
+		if _, err := fmt.Sscanf(s, "%d %s", &pref, &target); err != nil {
+			return nil, fmt.Errorf("bad MX: %v", s)
+		}
+		name, err := parsePresentName(target)
+		if err != nil {
+			return nil, err
+		}
+		var b [2]byte
+		binary.BigEndian.PutUint16(b[:], pref)
+		return append(b[:], PackName(name)...), nil
+	default:
+		return nil, fmt.Errorf("rdataHEX is required for %v", t)
+	}
+}
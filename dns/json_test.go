@@ -0,0 +1,65 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	q := NewQuery("example.com.", A)
+	ans := NewAnswer(q.Question)
+	ans.ReplyTo(q)
+	ans.Answers = append(ans.Answers, NewResourceRecord("example.com.", A, IN, 300, []byte{93, 184, 216, 34}))
+	ans.Authorities = append(ans.Authorities, NewResourceRecord("example.com.", NS, IN, 60, PackName("ns.example.com.")))
+
+	b, err := json.Marshal(ans)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	s := string(b)
+	for _, expected := range []string{
+		`"QR":true`, `"Opcode":0`, `"RCODE":0`, `"ANCOUNT":1`, `"QNAME":"example.com."`, `"QTYPE":1`,
+		`"rdataA":"93.184.216.34"`, `"rdataNS":"ns.example.com."`, `"rdataHEX":"5db8d822"`,
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("%v should contain %v", s, expected)
+		}
+	}
+
+	var decoded Answer
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	packed, _ := ans.Pack()
+	repacked, _ := decoded.Pack()
+	if !bytes.Equal(packed, repacked) {
+		t.Fatalf("round trip failed: %v, %v", packed, repacked)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	s := `{"ID": 32784, "QR": true, "Opcode": 0, "AA": true, "RCODE": 0,
+		"QNAME": "example.com.", "QTYPE": 1, "QCLASS": 1,
+		"answerRRs": [{"NAME": "example.com.", "TYPE": 1, "CLASS": 1, "TTL": 3600, "rdataA": "192.0.2.1"},
+			{"NAME": "example.com.", "TYPE": 65280, "CLASS": 1, "TTL": 3600, "rdataHEX": "0102"}]}`
+	var ans Answer
+	if err := json.Unmarshal([]byte(s), &ans); err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if ans.Header.ID() != 32784 || !ans.Header.AA() || len(ans.Questions) != 1 || len(ans.Answers) != 2 {
+		t.Fatalf("unexpected answer: %v", ans)
+	}
+	if ip, _ := ans.Answers[0].IP(); ip.String() != "192.0.2.1" {
+		t.Fatalf("unexpected A: %v", ip)
+	}
+	if !bytes.Equal(ans.Answers[1].Rdata, []byte{1, 2}) {
+		t.Fatalf("unexpected rdata: %v", ans.Answers[1].Rdata)
+	}
+
+	bad := `{"answerRRs": [{"NAME": "example.com.", "TYPE": 16, "CLASS": 1, "TTL": 3600, "rdataTXT": "\"x\""}]}`
+	if err := json.Unmarshal([]byte(bad), &ans); err == nil {
+		t.Fatalf("TXT without rdataHEX should be error")
+	}
+}
@@ -0,0 +1,261 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// ErrFormat means the message is malformed
+	ErrFormat = errors.New("malformed message")
+	// ErrNotImp means the opcode is not supported
+	ErrNotImp = errors.New("opcode not implemented")
+)
+
+const (
+	maxNameLength = 255
+	maxPointers   = 64
+)
+
+// parseName reads name at begin following compression pointers, it never panics on broken input.
+func parseName(p []byte, begin int) (string, int, error) {
+	name := ""
+	n := 0
+	pos := begin
+	jumped := false
+	for ptrs := 0; ; {
+		if pos >= len(p) {
+			return "", 0, ErrFormat
+		}
+		l := int(p[pos])
+		switch {
+		case l == 0:
+			if !jumped {
+				n = pos - begin + 1
+			}
+			return name, n, nil
+		case l&0xC0 == 0xC0:
+			if pos+1 >= len(p) {
+				return "", 0, ErrFormat
+			}
+			if !jumped {
+				n = pos - begin + 2
+				jumped = true
+			}
+			ptrs++
+			if ptrs > maxPointers {
+				return "", 0, ErrFormat
+			}
+			pos = int(binary.BigEndian.Uint16(p[pos:]) & 0x3FFF)
+		case l > 63:
+			return "", 0, ErrFormat
+		default:
+			if pos+1+l > len(p) {
+				return "", 0, ErrFormat
+			}
+			name += string(p[pos+1:pos+1+l]) + "."
+			if len(name) > maxNameLength {
+				return "", 0, ErrFormat
+			}
+			pos += l + 1
+		}
+	}
+}
+
+// skipResourceRecord returns type, class, rdata and size of rr at begin.
+func skipResourceRecord(p []byte, begin int) (QueryType, Class, []byte, int, error) {
+	_, n, err := parseName(p, begin)
+	if err != nil {
+		return 0, 0, nil, 0, err
+	}
+	if begin+n+10 > len(p) {
+		return 0, 0, nil, 0, ErrFormat
+	}
+	t := QueryType(binary.BigEndian.Uint16(p[begin+n:]))
+	class := Class(binary.BigEndian.Uint16(p[begin+n+2:]))
+	rdLength := int(binary.BigEndian.Uint16(p[begin+n+8:]))
+	if begin+n+10+rdLength > len(p) {
+		return 0, 0, nil, 0, ErrFormat
+	}
+	return t, class, p[begin+n+10 : begin+n+10+rdLength], n + 10 + rdLength, nil
+}
+
+// ParseQuery parses query from client, Header is filled if readable even when it fails
+func ParseQuery(p []byte) (Query, error) {
+	h, offset, err := parseHeader(p)
+	if err != nil {
+		return Query{}, ErrFormat
+	}
+	q := Query{Header: h}
+	if h.qr() || h.qdCount() != 1 {
+		return q, ErrFormat
+	}
+	if h.opCode() != 0 {
+		return q, ErrNotImp
+	}
+	name, n, err := parseName(p, offset)
+	if err != nil {
+		return q, err
+	}
+	if offset+n+4 > len(p) {
+		return q, ErrFormat
+	}
+	q.Question = NewQuestion(name, QueryType(binary.BigEndian.Uint16(p[offset+n:])))
+	offset += n + 4
+	rest := int(h.anCount()) + int(h.nsCount()) + int(h.arCount())
+	for i := 0; i < rest; i++ {
+		t, class, rdata, rn, err := skipResourceRecord(p, offset)
+		if err != nil {
+			return q, err
+		}
+		if t == OPT && i >= rest-int(h.arCount()) {
+			// 512 未満は 512 とみなす。 see RFC 6891
+			q.udpSize = uint16(class)
+			if q.udpSize < 512 {
+				q.udpSize = 512
+			}
+			q.options = rdata
+		}
+		offset += rn
+	}
+	return q, nil
+}
+
+// rdata returns rdata without compression.
+func (r *ResourceRecord) rdata() ([]byte, error) {
+	switch r.T {
+	case CNAME, NS, DNAME, PTR:
+		name, _, err := parseName(r.head, r.RdataOffset)
+		if err != nil {
+			return nil, err
+		}
+		return PackName(name), nil
+	case MX:
+		if len(r.Rdata) < 3 {
+			return nil, ErrFormat
+		}
+		name, _, err := parseName(r.head, r.RdataOffset+2)
+		if err != nil {
+			return nil, err
+		}
+		return append(append([]byte{}, r.Rdata[:2]...), PackName(name)...), nil
+	case SOA:
+		mname, mn, err := parseName(r.head, r.RdataOffset)
+		if err != nil {
+			return nil, err
+		}
+		rname, rn, err := parseName(r.head, r.RdataOffset+mn)
+		if err != nil {
+			return nil, err
+		}
+		if mn+rn+20 > len(r.Rdata) {
+			return nil, ErrFormat
+		}
+		rdata := append(PackName(mname), PackName(rname)...)
+		return append(rdata, r.Rdata[mn+rn:]...), nil
+	default:
+		return r.Rdata, nil
+	}
+}
+
+func packResourceRecord(b []byte, r *ResourceRecord) ([]byte, error) {
+	rdata, err := r.rdata()
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, PackName(r.Name)...)
+	var fixed [10]byte
+	binary.BigEndian.PutUint16(fixed[0:], uint16(r.T))
+	binary.BigEndian.PutUint16(fixed[2:], uint16(r.Class))
+	binary.BigEndian.PutUint32(fixed[4:], r.TTL)
+	binary.BigEndian.PutUint16(fixed[8:], uint16(len(rdata)))
+	b = append(b, fixed[:]...)
+	return append(b, rdata...), nil
+}
+
+// Pack returns answer in wire format, names are not compressed
+func (a *Answer) Pack() ([]byte, error) {
+	return a.pack(a.Answers, a.Authorities, a.Additionals)
+}
+
+// PackWithin packs answer into size bytes, records are dropped and TC is set if it does not fit
+func (a *Answer) PackWithin(size int) ([]byte, error) {
+	b, err := a.Pack()
+	if err != nil || len(b) <= size {
+		return b, err
+	}
+	// OPT は残す。 see RFC 6891 7
+	opts := []ResourceRecord{}
+	for _, rr := range a.Additionals {
+		if rr.T == OPT {
+			opts = append(opts, rr)
+		}
+	}
+	a.Header.setTC(true)
+	return a.pack(nil, nil, opts)
+}
+
+func (a *Answer) pack(answers, authorities, additionals []ResourceRecord) ([]byte, error) {
+	h := a.Header
+	h.done = false
+	h.setQDCount(uint16(len(a.Questions)))
+	h.setANCount(uint16(len(answers)))
+	h.setNSCount(uint16(len(authorities)))
+	h.setARCount(uint16(len(additionals)))
+	b := make([]byte, 12, 512)
+	if _, err := h.Read(b); err != nil {
+		return nil, err
+	}
+	for _, q := range a.Questions {
+		b = append(b, PackName(q.name)...)
+		var fixed [4]byte
+		binary.BigEndian.PutUint16(fixed[0:], uint16(q.t))
+		binary.BigEndian.PutUint16(fixed[2:], IN)
+		b = append(b, fixed[:]...)
+	}
+	for _, rrs := range [][]ResourceRecord{answers, authorities, additionals} {
+		for i := range rrs {
+			var err error
+			if b, err = packResourceRecord(b, &rrs[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// ReplyTo makes a the response of q, restoring id and question of the client
+func (a *Answer) ReplyTo(q Query) {
+	a.Header.setID(q.Header.ID())
+	a.Header.setQR(true)
+	a.Header.setRD(q.Header.rd())
+	a.Questions = []Question{q.Question}
+	// OPT は hop-by-hop なので upstream のものは落とす。
+	additionals := make([]ResourceRecord, 0, len(a.Additionals))
+	for _, rr := range a.Additionals {
+		if rr.T != OPT {
+			additionals = append(additionals, rr)
+		}
+	}
+	a.Additionals = additionals
+}
+
+// NewErrorAnswer is ctor of Answer replying rcode to q
+func NewErrorAnswer(q Query, rcode int) Answer {
+	a := Answer{}
+	a.Header.setID(q.Header.ID())
+	a.Header.setQR(true)
+	a.Header.setOpCode(q.Header.opCode())
+	a.Header.setRD(q.Header.rd())
+	a.Header.SetRCode(rcode)
+	if rcode != FormErr {
+		// FORMERR では question も信用できない。
+		a.Questions = []Question{q.Question}
+	}
+	return a
+}
+
+// SetEDNS0 adds OPT record advertising udp payload size with options. see RFC 6891
+func (a *Answer) SetEDNS0(udpSize uint16, options []byte) {
+	a.Additionals = append(a.Additionals, NewResourceRecord("", OPT, Class(udpSize), 0, options))
+}
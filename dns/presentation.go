@@ -0,0 +1,161 @@
+package dns
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// presentName escapes name for master file, labels are kept separated by '.'.
+func presentName(name string) string {
+	if name == "" || name == "." {
+		return "."
+	}
+	return Escape([]byte(name))
+}
+
+// quoteCharString quotes <character-string>, spaces are kept as is in quotes.
+func quoteCharString(b []byte) string {
+	return `"` + escape(b, true) + `"`
+}
+
+// genericRdata shows rdata in unknown format. see RFC 3597 5
+func genericRdata(rdata []byte) string {
+	if len(rdata) == 0 {
+		return `\# 0`
+	}
+	return fmt.Sprintf(`\# %d %s`, len(rdata), hex.EncodeToString(rdata))
+}
+
+var opCodeNames = map[int]string{0: "QUERY", 1: "IQUERY", 2: "STATUS", 4: "NOTIFY", 5: "UPDATE"}
+
+var rCodeNames = map[int]string{
+	NoError:  "NOERROR",
+	FormErr:  "FORMERR",
+	ServFail: "SERVFAIL",
+	NXDomain: "NXDOMAIN",
+	NotImp:   "NOTIMP",
+	Refused:  "REFUSED",
+}
+
+func codeName(names map[int]string, code int) string {
+	if name, ok := names[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("RESERVED%d", code)
+}
+
+func (h *Header) flagNames() string {
+	flags := []string{}
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"qr", h.qr()}, {"aa", h.AA()}, {"tc", h.TC()}, {"rd", h.rd()},
+		{"ra", h.ra()}, {"ad", h.ad()}, {"cd", h.cd()},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	return strings.Join(flags, " ")
+}
+
+func (q Question) String() string {
+	return fmt.Sprintf(";%v\t\tIN\t%v", presentName(Fqdn(q.name)), q.t)
+}
+
+// String shows a in dig style
+func (a Answer) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, ";; ->>HEADER<<- opcode: %v, status: %v, id: %d\n",
+		codeName(opCodeNames, a.Header.opCode()), codeName(rCodeNames, a.Header.RCode()), a.Header.ID())
+
+	additionals := []ResourceRecord{}
+	var opt *ResourceRecord
+	for i, rr := range a.Additionals {
+		if rr.T == OPT {
+			opt = &a.Additionals[i]
+			continue
+		}
+		additionals = append(additionals, rr)
+	}
+	fmt.Fprintf(&sb, ";; flags: %v; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n",
+		a.Header.flagNames(), len(a.Questions), len(a.Answers), len(a.Authorities), len(a.Additionals))
+
+	if opt != nil {
+		// OPT の TTL は extended RCODE, version, flags。see RFC 6891 6.1.3
+		flags := ""
+		if opt.TTL&0x8000 != 0 {
+			flags = " do"
+		}
+		fmt.Fprintf(&sb, "\n;; OPT PSEUDOSECTION:\n; EDNS: version: %d, flags:%v; udp: %d\n", (opt.TTL>>16)&0xff, flags, opt.Class)
+		for b := opt.Rdata; len(b) >= 4; {
+			code := binary.BigEndian.Uint16(b)
+			l := int(binary.BigEndian.Uint16(b[2:]))
+			if len(b) < 4+l {
+				break
+			}
+			if EDNS0OptionCode(code) == EDNS0TCPKeepalive && l == 2 {
+				fmt.Fprintf(&sb, "; TCP KEEPALIVE: %.1f secs\n", float64(binary.BigEndian.Uint16(b[4:]))/10)
+			} else {
+				fmt.Fprintf(&sb, "; OPT=%d: %s\n", code, hex.EncodeToString(b[4:4+l]))
+			}
+			b = b[4+l:]
+		}
+	}
+
+	sb.WriteString("\n;; QUESTION SECTION:\n")
+	for _, q := range a.Questions {
+		sb.WriteString(q.String() + "\n")
+	}
+	for _, s := range []struct {
+		name string
+		rrs  []ResourceRecord
+	}{
+		{"ANSWER", a.Answers}, {"AUTHORITY", a.Authorities}, {"ADDITIONAL", additionals},
+	} {
+		if len(s.rrs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n;; %v SECTION:\n", s.name)
+		for _, rr := range s.rrs {
+			sb.WriteString(rr.String() + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// parsePresentName decodes escapes made by presentName.
+func parsePresentName(s string) (string, error) {
+	if s == "." {
+		return "", nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return "", fmt.Errorf("bad escape in %v", s)
+		}
+		if s[i+1] < '0' || s[i+1] > '9' {
+			sb.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if i+3 >= len(s) {
+			return "", fmt.Errorf("bad escape in %v", s)
+		}
+		n, err := strconv.ParseUint(s[i+1:i+4], 10, 8)
+		if err != nil {
+			return "", fmt.Errorf("bad escape in %v", s)
+		}
+		sb.WriteByte(byte(n))
+		i += 3
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,60 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResourceRecordString(t *testing.T) {
+	cases := []struct {
+		name     string
+		rr       ResourceRecord
+		expected string
+	}{
+		{"A", NewResourceRecord("example.com.", A, IN, 300, []byte{93, 184, 216, 34}), "example.com.\t300\tIN\tA\t93.184.216.34"},
+		{"CNAME", NewResourceRecord("www.example.com.", CNAME, IN, 60, PackName("example.com.")), "www.example.com.\t60\tIN\tCNAME\texample.com."},
+		{"MX", NewResourceRecord("example.com.", MX, IN, 60, append([]byte{0, 10}, PackName("mx.example.com.")...)), "example.com.\t60\tIN\tMX\t10 mx.example.com."},
+		{"TXT", NewResourceRecord("example.com.", TXT, IN, 60, []byte("\x0bhello \"dns\"\x02\x00\\")), "example.com.\t60\tIN\tTXT\t\"hello \\\"dns\\\"\" \"\\000\\\\\""},
+		{"SOA", NewResourceRecord("example.com.", SOA, IN, 3600, append(append(PackName("ns.example.com."), PackName("hm.example.com.")...), 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0, 5)),
+			"example.com.\t3600\tIN\tSOA\tns.example.com. hm.example.com. 1 2 3 4 5"},
+		{"unknown", NewResourceRecord("example.com.", QueryType(65280), IN, 60, []byte{0xde, 0xad}), "example.com.\t60\tIN\tTYPE65280\t\\# 2 dead"},
+		{"root", NewResourceRecord("", NS, IN, 60, PackName(".")), ".\t60\tIN\tNS\t."},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.rr.String(); got != c.expected {
+				t.Fatalf("expected: %q, but got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestAnswerString(t *testing.T) {
+	q := NewQuery("example.com.", A)
+	ans := NewAnswer(q.Question)
+	ans.ReplyTo(q)
+	ans.Answers = append(ans.Answers, NewResourceRecord("example.com.", A, IN, 300, []byte{93, 184, 216, 34}))
+	ans.SetEDNS0(1232, nil)
+
+	s := ans.String()
+	for _, expected := range []string{
+		";; ->>HEADER<<- opcode: QUERY, status: NOERROR, id: ",
+		";; flags: qr rd ra; QUERY: 1, ANSWER: 1, AUTHORITY: 0, ADDITIONAL: 1\n",
+		"; EDNS: version: 0, flags:; udp: 1232\n",
+		";; QUESTION SECTION:\n;example.com.\t\tIN\tA\n",
+		";; ANSWER SECTION:\nexample.com.\t300\tIN\tA\t93.184.216.34\n",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Fatalf("%q should contain %q", s, expected)
+		}
+	}
+	if strings.Contains(s, "ADDITIONAL SECTION") {
+		t.Fatalf("OPT should be shown as pseudosection: %v", s)
+	}
+}
+
+func TestQuoteCharString(t *testing.T) {
+	if quoted := quoteCharString([]byte("a b\"\\;()@$\x00\xff.")); quoted != `"a b\"\\;()@$\000\255."` {
+		t.Fatalf("unexpected quote: %v", quoted)
+	}
+}
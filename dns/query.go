@@ -2,11 +2,11 @@ package dns
 
 import (
 	"bytes"
+	crand "crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net"
 	"strings"
 )
@@ -37,6 +37,8 @@ type Question struct {
 type Query struct {
 	Header   Header
 	Question Question
+	udpSize  uint16
+	options  []byte
 	done     bool
 }
 
@@ -62,114 +64,166 @@ type Answer struct {
 	head        []byte
 }
 
+// NewResourceRecord is ctor of ResourceRecord, rdata must not be compressed
+func NewResourceRecord(name string, t QueryType, class Class, ttl uint32, rdata []byte) ResourceRecord {
+	return ResourceRecord{
+		Name:        name,
+		T:           t,
+		Class:       class,
+		TTL:         ttl,
+		RdLength:    uint16(len(rdata)),
+		RdataOffset: 0,
+		Rdata:       rdata,
+		head:        rdata,
+	}
+}
+
 func (r ResourceRecord) String() string {
-	return fmt.Sprintf("{Name: %v, Type: %v, Class: %v, TTL: %d, RdLength: %d, Rdata: %v}",
-		r.Name,
-		r.T,
-		r.Class,
+	return fmt.Sprintf("%v\t%d\t%v\t%v\t%v",
+		presentName(r.Name),
 		r.TTL,
-		r.RdLength,
+		r.Class,
+		r.T,
 		r.ShowRdata(r.T),
 	)
 }
 
-func readStringWithLength(p []byte) (string, int) {
-	l := int(p[0])
-	s := ""
-	offset := 1
-	for i := 0; i < l; i++ {
-		s += string(p[offset+i])
-	}
-	return s, l + 1
-}
-
-func parseSVCParams(data []byte) map[int]string {
-	params := map[int]string{}
-	offset := 0
-	for offset < len(data) {
-		key := int(binary.BigEndian.Uint16(data[offset : offset+2]))
-		offset += 2
-		valLen := int(binary.BigEndian.Uint16(data[offset : offset+2])) // val len
-		valData := data[offset+2 : offset+2+valLen]
-		offset += 2 + valLen
-		val := ""
-		switch key {
-		// see https://www.iana.org/assignments/dns-svcb/dns-svcb.xhtml
-		case 1: // alpn
-			ss := []string{}
-			for i := 0; i < valLen; {
-				s, advance := readStringWithLength(valData[i:])
-				i += advance
-				ss = append(ss, s)
-			}
-			val = "apln=" + strings.Join(ss, ",")
-		case 3: // port
-			val = fmt.Sprintf("port=%v", int(binary.BigEndian.Uint16(valData)))
-		case 4: // ipv4hint
-			if len(valData)%4 != 0 {
-				panic("what's??")
-			}
-			addrs := []string{}
-			for i := 0; i < len(valData); i += 4 {
-				addrs = append(addrs, fmt.Sprintf("%v", net.IP(valData[i:i+4])))
-			}
-			val = fmt.Sprintf("ipv4hint='%v'", strings.Join(addrs, ", "))
-		case 6: // ipv6hint
-			if len(valData)%16 != 0 {
-				panic("what's??")
-			}
-			addrs := []string{}
-			for i := 0; i < len(valData); i += 16 {
-				addrs = append(addrs, fmt.Sprintf("%v", net.IP(valData[i:i+16])))
-			}
-			val = fmt.Sprintf("ipv6hint='%v'", strings.Join(addrs, ", "))
-		case 7: // dohpath
-			val = "dohpath=" + string(valData)
-		default:
-			val = fmt.Sprintf("key %v=%v", key, string(valData))
-		}
-		params[key] = val
-	}
-	return params
-}
-
-// ShowRdata shows rr rdata
+// ShowRdata shows rr rdata in presentation format
 func (r *ResourceRecord) ShowRdata(t QueryType) string {
 	switch t {
 	case A, AAAA:
 		return fmt.Sprintf("%v", net.IP(r.Rdata))
-	case CNAME, NS:
-		name, _ := readName(r.head, r.RdataOffset)
-		return name
+	case CNAME, NS, DNAME, PTR:
+		name, err := r.rdataName()
+		if err != nil {
+			return genericRdata(r.Rdata)
+		}
+		return presentName(name)
 	case SOA:
-		mname, mn := readName(r.head, r.RdataOffset)
-		rname, rn := readName(r.head, r.RdataOffset+mn)
-		serial := binary.BigEndian.Uint32(r.head[r.RdataOffset+mn+rn:])
-		return fmt.Sprintf("{mname: %v, rname: %v, serial: %v}", mname, rname, serial)
-	case SVCB:
-		priority := binary.BigEndian.Uint16(r.head[r.RdataOffset:])
-		target, tn := readName(r.head, r.RdataOffset+2)
-		params := parseSVCParams(r.head[r.RdataOffset+2+tn : r.RdataOffset+int(r.RdLength)])
-		return fmt.Sprintf("{priority: %v, target: %v, rest: %v}", priority, target, params)
+		mname, mn, err := parseName(r.head, r.RdataOffset)
+		if err != nil {
+			return genericRdata(r.Rdata)
+		}
+		rname, rn, err := parseName(r.head, r.RdataOffset+mn)
+		if err != nil || mn+rn+20 > len(r.Rdata) {
+			return genericRdata(r.Rdata)
+		}
+		timers := r.Rdata[mn+rn:]
+		return fmt.Sprintf("%v %v %d %d %d %d %d", presentName(mname), presentName(rname),
+			binary.BigEndian.Uint32(timers),
+			binary.BigEndian.Uint32(timers[4:]),
+			binary.BigEndian.Uint32(timers[8:]),
+			binary.BigEndian.Uint32(timers[12:]),
+			binary.BigEndian.Uint32(timers[16:]),
+		)
+	case MX:
+		if len(r.Rdata) < 3 {
+			return genericRdata(r.Rdata)
+		}
+		name, _, err := parseName(r.head, r.RdataOffset+2)
+		if err != nil {
+			return genericRdata(r.Rdata)
+		}
+		return fmt.Sprintf("%d %v", binary.BigEndian.Uint16(r.Rdata), presentName(name))
+	case TXT:
+		strs := []string{}
+		for b := r.Rdata; len(b) > 0 && len(b) > int(b[0]); b = b[1+int(b[0]):] {
+			strs = append(strs, quoteCharString(b[1:1+int(b[0])]))
+		}
+		return strings.Join(strs, " ")
+	case SVCB, HTTPS:
+		s, err := r.SVCB()
+		if err != nil {
+			return genericRdata(r.Rdata)
+		}
+		return s.String()
 	default:
-		return "unknown"
+		return genericRdata(r.Rdata)
 	}
 }
 
+// rdataName reads the name at the head of rdata as is.
+func (r *ResourceRecord) rdataName() (string, error) {
+	name, _, err := parseName(r.head, r.RdataOffset)
+	return name, err
+}
+
 // CNAMETO returns rr cname if it is cname
 func (r *ResourceRecord) CNAMETO() (string, error) {
 	if r.T != CNAME {
 		return "", errors.New("not CNAME")
 	}
-	return r.ShowRdata(CNAME), nil
+	return r.rdataName()
+}
+
+// DNAMETO returns rr dname target if it is dname
+func (r *ResourceRecord) DNAMETO() (string, error) {
+	if r.T != DNAME {
+		return "", errors.New("not DNAME")
+	}
+	return r.rdataName()
+}
+
+// SVCB returns decoded rdata if it is SVCB or HTTPS
+func (r *ResourceRecord) SVCB() (SVCBResult, error) {
+	if r.T != SVCB && r.T != HTTPS {
+		return SVCBResult{}, errors.New("not SVCB or HTTPS")
+	}
+	if r.RdLength < 3 {
+		return SVCBResult{}, errors.New("too short SVCB rdata")
+	}
+	priority := binary.BigEndian.Uint16(r.head[r.RdataOffset:])
+	target, tn, err := parseName(r.head, r.RdataOffset+2)
+	if err != nil {
+		return SVCBResult{}, err
+	}
+	if 2+tn > len(r.Rdata) {
+		return SVCBResult{}, ErrFormat
+	}
+	if target == "" {
+		target = "."
+	}
+	params, err := ParseSvcParams(r.Rdata[2+tn:])
+	if err != nil {
+		return SVCBResult{}, err
+	}
+	return SVCBResult{
+		Name:     r.Name,
+		Priority: priority,
+		Target:   target,
+		Params:   params,
+	}, nil
+}
+
+// PTRName returns name if it is PTR
+func (r *ResourceRecord) PTRName() (string, error) {
+	if r.T != PTR {
+		return "", errors.New("not PTR")
+	}
+	return r.rdataName()
+}
+
+// NSName returns name server name if it is NS
+func (r *ResourceRecord) NSName() (string, error) {
+	if r.T != NS {
+		return "", errors.New("not NS")
+	}
+	return r.rdataName()
 }
 
-// IP returns ip addr if it is A
+// IP returns ip addr if it is A or AAAA
 func (r *ResourceRecord) IP() (net.IP, error) {
-	if r.T != A {
-		return nil, errors.New("not A")
+	if (r.T == A && len(r.Rdata) != net.IPv4len) || (r.T == AAAA && len(r.Rdata) != net.IPv6len) {
+		return nil, ErrFormat
+	}
+	switch r.T {
+	case A:
+		return net.IPv4(r.Rdata[0], r.Rdata[1], r.Rdata[2], r.Rdata[3]), nil
+	case AAAA:
+		return net.IP(append([]byte{}, r.Rdata...)), nil
+	default:
+		return nil, errors.New("not A or AAAA")
 	}
-	return net.IPv4(r.Rdata[0], r.Rdata[1], r.Rdata[2], r.Rdata[3]), nil
 }
 
 // NewHeader is ctor of Header
@@ -178,14 +232,23 @@ func NewHeader() Header {
 }
 
 func newHeaderContent() headerContent {
-	id := uint16(rand.Uint32())
-	//fmt.Printf("id: %v\n", id)
 	return headerContent{
-		ID: id,
+		ID: newID(),
 	}
 }
 
-func (h *Header) id() uint16 {
+// newID makes unpredictable message id
+func newID() uint16 {
+	// 予測できると 0x20 があっても偽装される。
+	var b [2]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// ID returns message id
+func (h *Header) ID() uint16 {
 	return h.c.ID
 }
 func (h *Header) setID(id uint16) {
@@ -217,15 +280,49 @@ func (h *Header) setRD(qr bool) {
 	}
 }
 
+// SetRA sets whether recursion is available
+func (h *Header) SetRA(ra bool) {
+	h.c.Flags = (h.c.Flags & 0xff7f)
+	if ra {
+		h.c.Flags = h.c.Flags | (1 << 7)
+	}
+}
+
+// SetRCode sets response code
+func (h *Header) SetRCode(rcode int) {
+	h.c.Flags = (h.c.Flags & 0xfff0) | uint16(rcode&0xf)
+}
+
 func (h *Header) opCode() int {
 	return int(h.c.Flags&0x7800) >> 11
 }
-func (h *Header) aa() bool {
+func (h *Header) setOpCode(opCode int) {
+	h.c.Flags = (h.c.Flags & 0x87ff) | uint16(opCode&0xf)<<11
+}
+
+// AA returns whether answer is authoritative
+func (h *Header) AA() bool {
 	return (h.c.Flags & 0x0400) != 0
 }
-func (h *Header) tc() bool {
+
+// SetAA sets whether answer is authoritative
+func (h *Header) SetAA(aa bool) {
+	h.c.Flags = (h.c.Flags & 0xfbff)
+	if aa {
+		h.c.Flags = h.c.Flags | (1 << 10)
+	}
+}
+
+// TC returns whether answer is truncated
+func (h *Header) TC() bool {
 	return (h.c.Flags & 0x0200) != 0
 }
+func (h *Header) setTC(tc bool) {
+	h.c.Flags = (h.c.Flags & 0xfdff)
+	if tc {
+		h.c.Flags = h.c.Flags | (1 << 9)
+	}
+}
 func (h *Header) ra() bool {
 	return (h.c.Flags & 0x80) != 0
 }
@@ -238,7 +335,21 @@ func (h *Header) ad() bool {
 func (h *Header) cd() bool {
 	return (h.c.Flags & 0x10) != 0
 }
-func (h *Header) rCode() int {
+func (h *Header) setAD(ad bool) {
+	h.c.Flags = (h.c.Flags & 0xffdf)
+	if ad {
+		h.c.Flags = h.c.Flags | (1 << 5)
+	}
+}
+func (h *Header) setCD(cd bool) {
+	h.c.Flags = (h.c.Flags & 0xffef)
+	if cd {
+		h.c.Flags = h.c.Flags | (1 << 4)
+	}
+}
+
+// RCode returns response code
+func (h *Header) RCode() int {
 	return int(h.c.Flags & 0xf)
 }
 
@@ -271,14 +382,14 @@ func (h Header) String() string {
 		h.c.ID,
 		h.qr(),
 		h.opCode(),
-		h.aa(),
-		h.tc(),
+		h.AA(),
+		h.TC(),
 		h.rd(),
 		h.ra(),
 		h.z(),
 		h.ad(),
 		h.cd(),
-		h.rCode(),
+		h.RCode(),
 		h.c.QdCount,
 		h.c.AnCount,
 		h.c.NsCount,
@@ -305,8 +416,7 @@ func (h *Header) Read(p []byte) (n int, err error) {
 // WriteName writes name
 func WriteName(p []byte, name string) int {
 	n := 0
-	labels := strings.Split(name, ".")
-	for _, label := range labels {
+	for _, label := range SplitLabels(name) {
 		len := len(label)
 		p[n] = byte(len)
 		n++
@@ -315,14 +425,22 @@ func WriteName(p []byte, name string) int {
 			n++
 		}
 	}
-	return n
+	p[n] = 0
+	return n + 1
+}
+
+// PackName returns name in wire format
+func PackName(name string) []byte {
+	p := make([]byte, len(name)+2)
+	n := WriteName(p, name)
+	return p[:n]
 }
 
 func (q *Question) Read(p []byte) (n int, err error) {
 	if q.done {
 		return 0, io.EOF
 	}
-	name := Normalize(q.name) //
+	name := Fqdn(q.name) // 0x20 のため大文字小文字はそのまま送る。
 	n = WriteName(p, name)
 	binary.BigEndian.PutUint16(p[n:], uint16(q.t))
 	binary.BigEndian.PutUint16(p[n+2:], IN)
@@ -330,17 +448,43 @@ func (q *Question) Read(p []byte) (n int, err error) {
 	return n + 4, nil
 }
 
+// NewQuestion is ctor of Question
+func NewQuestion(name string, t QueryType) Question {
+	return Question{name: name, t: t}
+}
+
+// Name returns question name as is
+func (q *Question) Name() string {
+	return q.name
+}
+
+// Type returns question type
+func (q *Question) Type() QueryType {
+	return q.t
+}
+
 // NewQuery is ctor of Query
 func NewQuery(domain string, t QueryType) Query {
 	q := Query{
 		Header:   NewHeader(),
-		Question: Question{name: domain, t: t},
+		Question: NewQuestion(domain, t),
 	}
 	q.Header.setQDCount(1)
 	q.Header.setRD(true)
 	return q
 }
 
+// SetEDNS0 adds OPT record advertising udp payload size. see RFC 6891
+func (q *Query) SetEDNS0(udpSize uint16) {
+	q.udpSize = udpSize
+	q.Header.setARCount(1)
+}
+
+// EDNS0 returns advertised udp payload size, or 0 if EDNS0 is not used
+func (q *Query) EDNS0() uint16 {
+	return q.udpSize
+}
+
 func (q *Query) Read(p []byte) (n int, err error) {
 	if q.done {
 		return 0, io.EOF
@@ -353,6 +497,17 @@ func (q *Query) Read(p []byte) (n int, err error) {
 	if err != nil {
 		return qn, err
 	}
+	if q.udpSize > 0 {
+		// root, OPT, udp size, ttl 0, options
+		opt := p[hn+qn : hn+qn+11+len(q.options)]
+		opt[0] = 0
+		binary.BigEndian.PutUint16(opt[1:], OPT)
+		binary.BigEndian.PutUint16(opt[3:], q.udpSize)
+		binary.BigEndian.PutUint32(opt[5:], 0)
+		binary.BigEndian.PutUint16(opt[9:], uint16(len(q.options)))
+		copy(opt[11:], q.options)
+		qn += len(opt)
+	}
 	q.done = true
 	return hn + qn, nil
 }
@@ -367,45 +522,32 @@ func parseHeader(p []byte) (Header, int, error) {
 	return Header{c: h}, 12, nil
 }
 
-func readName(p []byte, begin int) (string, int) {
-	n := 0
-	name := ""
-	l := int(p[begin])
-
-	for l != 0 {
-		//fmt.Printf("l: %v\n", l)
-		if l > 64 {
-			off := binary.BigEndian.Uint16(p[begin+n:]) - 0xC000
-			//fmt.Printf("compression!(n: %d, offset: %d)\n", l, off)
-			//fmt.Printf("p[begin:begin+2]: %v\n", p[begin+n:begin+n+2])
-			//fmt.Printf("p[begin]: %v\n", int(p[begin+n]))
-			//fmt.Printf("p[off]: %v\n", p[n+int(off):])
-			suf, _ := readName(p, int(off))
-			return name + suf, n + 2
-		}
-
-		label := string(p[begin+n+1 : begin+n+l+1])
-		name = name + label + "."
-		n = n + l + 1
-		l = int(p[begin+n])
-		//fmt.Printf("l: %v, n: %d, l: %d\n", label, n, l)
+func parseQuestion(p []byte, begin int) (Question, int, error) {
+	name, n, err := parseName(p, begin)
+	if err != nil {
+		return Question{}, 0, err
 	}
-	return name, n + 1
-}
-
-func parseQuestion(p []byte) (Question, int, error) {
-	name, n := readName(p, 0)
-	return Question{name: name, t: QueryType(binary.BigEndian.Uint16(p[n:]))}, n + 4, nil
+	if begin+n+4 > len(p) {
+		return Question{}, 0, ErrFormat
+	}
+	return Question{name: name, t: QueryType(binary.BigEndian.Uint16(p[begin+n:]))}, n + 4, nil
 }
 
-func parseResourceRecord(p []byte, begin int, head []byte) (ResourceRecord, int, error) {
-	name, n := readName(p, begin)
+func parseResourceRecord(p []byte, begin int) (ResourceRecord, int, error) {
+	name, n, err := parseName(p, begin)
+	if err != nil {
+		return ResourceRecord{}, 0, err
+	}
+	if begin+n+10 > len(p) {
+		return ResourceRecord{}, 0, ErrFormat
+	}
 	t := QueryType(binary.BigEndian.Uint16(p[begin+n:]))
 	class := Class(binary.BigEndian.Uint16(p[begin+n+2:]))
 	ttl := binary.BigEndian.Uint32(p[begin+n+4:])
 	rdLength := binary.BigEndian.Uint16(p[begin+n+8:])
-	//	fmt.Printf("name: %v, n: %d, type: %d, class: %d, ttl: %d, rdsize: %d\n", name, n, t, class, ttl, rdLength)
-	//	fmt.Printf("A: %d.%d.%d.%d\n", p[begin+n+10], p[begin+n+11], p[begin+n+12], p[begin+n+13]) // A!
+	if begin+n+10+int(rdLength) > len(p) {
+		return ResourceRecord{}, 0, ErrFormat
+	}
 	return ResourceRecord{
 		Name:        name,
 		T:           t,
@@ -414,61 +556,64 @@ func parseResourceRecord(p []byte, begin int, head []byte) (ResourceRecord, int,
 		RdLength:    rdLength,
 		RdataOffset: begin + n + 10,
 		Rdata:       p[begin+n+10 : begin+n+10+int(rdLength)],
-		head:        head,
+		head:        p,
 	}, n + 10 + int(rdLength), nil
 }
 
-// ParseAnswer parses answer from server
+// NewAnswer is ctor of Answer replying to q
+func NewAnswer(q Question) Answer {
+	h := Header{}
+	h.setQR(true)
+	h.setRD(true)
+	h.SetRA(true)
+	h.setQDCount(1)
+	return Answer{
+		Header:    h,
+		Questions: []Question{q},
+	}
+}
+
+// ParseAnswer parses answer from server, it returns ErrFormat if ans is malformed
 func ParseAnswer(ans []byte) (Answer, error) {
 	result := Answer{}
 	h, offset, err := parseHeader(ans)
-	fmt.Printf("anser header: %v\n", h)
 	if err != nil {
-		return result, err
+		return result, ErrFormat
 	}
 	result.head = ans
 	result.Header = h
-	result.Questions = make([]Question, h.qdCount())
-	result.Answers = make([]ResourceRecord, h.anCount())
-	result.Authorities = make([]ResourceRecord, h.nsCount())
-	result.Additionals = make([]ResourceRecord, h.arCount())
+	// count は信用できないので先に確保しない。
+	result.Questions = []Question{}
+	result.Answers = []ResourceRecord{}
+	result.Authorities = []ResourceRecord{}
+	result.Additionals = []ResourceRecord{}
 	for i := 0; i < int(h.qdCount()); i++ {
-		q, qn, err := parseQuestion(ans[offset:])
-		fmt.Printf("answer question: %v(size: %v)\n", q, qn)
+		q, qn, err := parseQuestion(ans, offset)
 		if err != nil {
 			return result, err
 		}
-		result.Questions[i] = q
+		result.Questions = append(result.Questions, q)
 		offset += qn
 	}
-	for i := 0; i < int(h.anCount()); i++ {
-		a, an, err := parseResourceRecord(ans, offset, result.head)
-		fmt.Printf("answer anser: %v(size: %v)\n", a, an)
-		if err != nil {
-			return result, err
-		}
-		result.Answers[i] = a
-		offset += an
+	sections := []struct {
+		count uint16
+		rrs   *[]ResourceRecord
+	}{
+		{h.anCount(), &result.Answers},
+		{h.nsCount(), &result.Authorities},
+		{h.arCount(), &result.Additionals},
 	}
-	for i := 0; i < int(h.nsCount()); i++ {
-		n, nn, err := parseResourceRecord(ans, offset, result.head)
-		fmt.Printf("answer ns: %v(size: %v)\n", n, nn)
-		if err != nil {
-			return result, err
-		}
-		result.Authorities[i] = n
-		offset += nn
-	}
-	for i := 0; i < int(h.arCount()); i++ {
-		a, an, err := parseResourceRecord(ans, offset, result.head)
-		fmt.Printf("answer additional: %v(size: %v)\n", a, an)
-		if err != nil {
-			return result, err
+	for _, s := range sections {
+		for i := 0; i < int(s.count); i++ {
+			rr, rn, err := parseResourceRecord(ans, offset)
+			if err != nil {
+				return result, err
+			}
+			*s.rrs = append(*s.rrs, rr)
+			offset += rn
 		}
-		result.Additionals[i] = a
-		offset += an
 	}
-	return result, err
+	return result, nil
 }
 
 func sameImp(lhss, rhss []string) bool {
@@ -489,15 +634,62 @@ func sameImp(lhss, rhss []string) bool {
 	return lhss[llen-1] == ""
 }
 
-// Normalize normalize rr name
-func Normalize(name string) string {
-	name = strings.ToLower(name)
+// Fqdn appends root dot to name, keeping its case
+func Fqdn(name string) string {
 	if !strings.HasSuffix(name, ".") {
 		name = name + "."
 	}
 	return name
 }
 
+// Normalize normalize rr name
+func Normalize(name string) string {
+	return strings.ToLower(Fqdn(name))
+}
+
+// Randomize0x20 flips case of each letter in name at random (draft-vixie-dnsext-dns0x20)
+func Randomize0x20(name string) string {
+	bits := make([]byte, (len(name)+7)/8)
+	if _, err := crand.Read(bits); err != nil {
+		return name
+	}
+	p := []byte(name)
+	for i, c := range p {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			continue
+		}
+		if bits[i/8]&(1<<uint(i%8)) != 0 {
+			p[i] = c ^ 0x20
+		}
+	}
+	return string(p)
+}
+
+// SplitLabels splits name into labels, root is dropped
+func SplitLabels(name string) []string {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return []string{}
+	}
+	return strings.Split(name, ".")
+}
+
+// IsSubDomain decides name is zone itself or under zone
+func IsSubDomain(name, zone string) bool {
+	ns := SplitLabels(name)
+	zs := SplitLabels(zone)
+	if len(ns) < len(zs) {
+		return false
+	}
+	ns = ns[len(ns)-len(zs):]
+	for i := range zs {
+		if !strings.EqualFold(ns[i], zs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 // Same decides args is same domain
 func Same(lhs, rhs string) bool {
 	lhss := strings.Split(Normalize(lhs), ".")
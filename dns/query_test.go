@@ -1,6 +1,8 @@
 package dns
 
 import (
+	"net"
+	"strings"
 	"testing"
 )
 
@@ -47,7 +49,7 @@ func TestNotSame(t *testing.T) {
 	}
 }
 
-func TestReadName(t *testing.T) {
+func TestParseName(t *testing.T) {
 	names := []struct {
 		name     []byte
 		len      int
@@ -58,15 +60,15 @@ func TestReadName(t *testing.T) {
 	}
 	for _, v := range names {
 		t.Run(v.expected, func(t *testing.T) {
-			name, len := readName(v.name, 0)
-			if !Same(name, v.expected) || len != v.len {
+			name, len, err := parseName(v.name, 0)
+			if err != nil || !Same(name, v.expected) || len != v.len {
 				t.Fatalf("expected (name, len): (%v, %v), but got (%v, %v).", v.expected, v.len, name, len)
 			}
 		})
 	}
 }
 
-func TestReadNameWithCompression(t *testing.T) {
+func TestParseNameWithCompression(t *testing.T) {
 	names := []struct {
 		name     []byte
 		len      int
@@ -81,8 +83,8 @@ func TestReadNameWithCompression(t *testing.T) {
 	}
 	for _, v := range names {
 		t.Run(v.expected, func(t *testing.T) {
-			name, len := readName(v.name, v.begin)
-			if !Same(name, v.expected) || len != v.len {
+			name, len, err := parseName(v.name, v.begin)
+			if err != nil || !Same(name, v.expected) || len != v.len {
 				t.Fatalf("expected (name, len): (%v, %v), but got (%v, %v).", v.expected, v.len, name, len)
 			}
 		})
@@ -99,10 +101,172 @@ func TestNameIdentity(t *testing.T) {
 		t.Run(v.name, func(t *testing.T) {
 			buf := make([]byte, 1500)
 			WriteName(buf, v.name)
-			name, _ := readName(buf, 0)
+			name, _, _ := parseName(buf, 0)
 			if !Same(name, v.name) {
 				t.Fatalf("expected: %v, but got %v.", v.name, name)
 			}
 		})
 	}
 }
+
+func TestFqdnKeepsCase(t *testing.T) {
+	names := []struct {
+		name     string
+		expected string
+	}{
+		{"ExAmple.com", "ExAmple.com."},
+		{"ExAmple.com.", "ExAmple.com."},
+		{"", "."},
+	}
+	for _, v := range names {
+		t.Run(v.name, func(t *testing.T) {
+			if got := Fqdn(v.name); got != v.expected {
+				t.Fatalf("expected: %v, but got %v.", v.expected, got)
+			}
+		})
+	}
+}
+
+func TestRandomize0x20(t *testing.T) {
+	name := "www.example-0x20.com."
+	for i := 0; i < 10; i++ {
+		r := Randomize0x20(name)
+		if !Same(r, name) {
+			t.Fatalf("%v and %v shold be same", r, name)
+		}
+		if strings.ToLower(r) != name {
+			t.Fatalf("only case should be changed: %v", r)
+		}
+	}
+}
+
+func TestIsSubDomain(t *testing.T) {
+	subs := []struct {
+		name string
+		zone string
+		sub  bool
+	}{
+		{"www.example.com", ".", true},
+		{"www.example.com", "com.", true},
+		{"www.example.com", "Example.COM", true},
+		{"www.example.com.", "www.example.com", true},
+		{"example.com", "www.example.com", false},
+		{"www.example.com", "ample.com", false},
+		{".", ".", true},
+	}
+	for _, v := range subs {
+		t.Run(v.name+"-"+v.zone, func(t *testing.T) {
+			if IsSubDomain(v.name, v.zone) != v.sub {
+				t.Fatalf("IsSubDomain(%v, %v) should be %v", v.name, v.zone, v.sub)
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	q := NewQuery("Example.COM", AAAA)
+	q.SetEDNS0(1232)
+	p := make([]byte, 512)
+	n, _ := q.Read(p)
+
+	parsed, err := ParseQuery(p[:n])
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if parsed.Header.ID() != q.Header.ID() || parsed.Question.Name() != "Example.COM." || parsed.Question.Type() != AAAA {
+		t.Fatalf("unexpected query: %v, %v", parsed.Header, parsed.Question)
+	}
+	if parsed.EDNS0() != 1232 {
+		t.Fatalf("expected: 1232, but got %v", parsed.EDNS0())
+	}
+	for i := 0; i < n; i++ {
+		if _, err := ParseQuery(p[:i]); err == nil {
+			t.Fatalf("truncated query(%v) should be error", i)
+		}
+	}
+}
+
+// compressedReply is an answer of www.example.com. A with compression.
+var compressedReply = []byte{
+	0x12, 0x34, 0x81, 0x80, 0, 1, 0, 2, 0, 0, 0, 0,
+	3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0, 0, 1, 0, 1,
+	0xc0, 12, 0, 5, 0, 1, 0, 0, 1, 0x2c, 0, 2, 0xc0, 16,
+	0xc0, 16, 0, 1, 0, 1, 0, 0, 1, 0x2c, 0, 4, 192, 0, 2, 1,
+}
+
+func TestParseAnswer(t *testing.T) {
+	ans, err := ParseAnswer(compressedReply)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if len(ans.Answers) != 2 || ans.Answers[0].ShowRdata(CNAME) != "example.com." || ans.Answers[1].Name != "example.com." {
+		t.Fatalf("unexpected answer: %v", ans)
+	}
+	for i := 0; i < len(compressedReply); i++ {
+		if _, err := ParseAnswer(compressedReply[:i]); err != ErrFormat {
+			t.Fatalf("truncated answer(%v) should be ErrFormat, but got %v", i, err)
+		}
+	}
+}
+
+func TestParseAnswerMalformed(t *testing.T) {
+	header := []byte{0x12, 0x34, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0}
+	cases := []struct {
+		name string
+		p    []byte
+	}{
+		{"lone pointer", append(append([]byte{}, header...), 3, 'w', 'w', 'w', 1, 'x', 0, 0, 1, 0xc0)},
+		{"pointer loop", append(append([]byte{}, header...), 0xc0, 12, 0, 1, 0, 1)},
+		{"pointer out of message", append(append([]byte{}, header...), 0xc0, 0xff, 0, 1, 0, 1)},
+		{"too long rdata", append(append([]byte{}, header[:6]...), 0, 1, 0, 0, 0, 0, 0, 0, 1, 0, 1, 0, 0, 1, 0, 1, 0, 0, 0, 0, 0, 10, 1, 2)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ParseAnswer(c.p); err != ErrFormat {
+				t.Fatalf("expected: ErrFormat, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestMalformedRdata(t *testing.T) {
+	rr := NewResourceRecord("www.example.com.", CNAME, IN, 300, []byte{0xc0})
+	if _, err := rr.CNAMETO(); err != ErrFormat {
+		t.Fatalf("expected: ErrFormat, but got %v", err)
+	}
+	if s := rr.ShowRdata(CNAME); s != `\# 1 c0` {
+		t.Fatalf("broken rdata should be shown as is: %v", s)
+	}
+	ans := NewAnswer(NewQuestion("www.example.com.", CNAME))
+	ans.Answers = []ResourceRecord{rr}
+	if _, err := ans.Pack(); err != ErrFormat {
+		t.Fatalf("expected: ErrFormat, but got %v", err)
+	}
+}
+
+func TestPackAnswer(t *testing.T) {
+	q := NewQuery("www.example.com", A)
+	ans := NewAnswer(NewQuestion("www.example.com.", A))
+	ans.Answers = []ResourceRecord{
+		NewResourceRecord("www.example.com.", CNAME, IN, 300, PackName("example.com.")),
+		NewResourceRecord("example.com.", A, IN, 300, []byte{192, 0, 2, 1}),
+	}
+	ans.ReplyTo(q)
+	p, err := ans.Pack()
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	parsed, err := ParseAnswer(p)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if parsed.Header.ID() != q.Header.ID() || len(parsed.Answers) != 2 {
+		t.Fatalf("unexpected answer: %v", parsed)
+	}
+	if target, _ := parsed.Answers[0].CNAMETO(); target != "example.com." {
+		t.Fatalf("expected: example.com., but got %v", target)
+	}
+	if ip, _ := parsed.Answers[1].IP(); !ip.Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Fatalf("expected: 192.0.2.1, but got %v", ip)
+	}
+}
@@ -0,0 +1,59 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// ReverseName returns in-addr.arpa or ip6.arpa name of ip
+func ReverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	ip6 := ip.To16()
+	if ip6 == nil {
+		return ""
+	}
+	var b strings.Builder
+	for i := len(ip6) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[ip6[i]&0xf])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[ip6[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+// ParseReverseName returns ip of in-addr.arpa or ip6.arpa name
+func ParseReverseName(name string) (net.IP, bool) {
+	labels := SplitLabels(Normalize(name))
+	switch {
+	case len(labels) == 6 && IsSubDomain(name, "in-addr.arpa."):
+		ip := make(net.IP, net.IPv4len)
+		for i := 0; i < 4; i++ {
+			n, err := strconv.ParseUint(labels[3-i], 10, 8)
+			if err != nil || (len(labels[3-i]) > 1 && labels[3-i][0] == '0') {
+				return nil, false
+			}
+			ip[i] = byte(n)
+		}
+		return ip, true
+	case len(labels) == 34 && IsSubDomain(name, "ip6.arpa."):
+		ip := make(net.IP, net.IPv6len)
+		for i := 0; i < 32; i++ {
+			l := labels[31-i]
+			if len(l) != 1 || strings.IndexByte(hexDigits, l[0]) < 0 {
+				return nil, false
+			}
+			ip[i/2] |= byte(strings.IndexByte(hexDigits, l[0])) << (4 * uint(1-i%2))
+		}
+		return ip, true
+	default:
+		return nil, false
+	}
+}
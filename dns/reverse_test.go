@@ -0,0 +1,43 @@
+package dns
+
+import (
+	"net"
+	"testing"
+)
+
+func TestReverseName(t *testing.T) {
+	cases := []struct {
+		ip   string
+		name string
+	}{
+		{"192.0.2.1", "1.2.0.192.in-addr.arpa."},
+		{"2001:db8::567:89ab", "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."},
+	}
+	for _, c := range cases {
+		t.Run(c.ip, func(t *testing.T) {
+			ip := net.ParseIP(c.ip)
+			if got := ReverseName(ip); got != c.name {
+				t.Fatalf("expected: %v, but got %v", c.name, got)
+			}
+			back, ok := ParseReverseName(c.name)
+			if !ok || !back.Equal(ip) {
+				t.Fatalf("expected: %v, but got %v", ip, back)
+			}
+		})
+	}
+}
+
+func TestParseReverseNameInvalid(t *testing.T) {
+	names := []string{
+		"2.0.192.in-addr.arpa.",
+		"256.2.0.192.in-addr.arpa.",
+		"01.2.0.192.in-addr.arpa.",
+		"1.2.0.192.example.",
+		"g.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	}
+	for _, name := range names {
+		if ip, ok := ParseReverseName(name); ok {
+			t.Fatalf("%v should be invalid, but got %v", name, ip)
+		}
+	}
+}
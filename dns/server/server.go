@@ -0,0 +1,405 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+)
+
+const (
+	// DefaultUDPSize is udp payload size advertised by the server
+	DefaultUDPSize = 1232
+	// DefaultIdleTimeout is time to keep idle tcp and tls connections. see RFC 7766 6.2.3
+	DefaultIdleTimeout = 10 * time.Second
+	// DefaultMaxConns is max number of tcp connections at once
+	DefaultMaxConns = 256
+	// DefaultMaxQueriesPerConn is max number of queries on a tcp connection
+	DefaultMaxQueriesPerConn = 128
+	// DefaultMaxUDPQueries is max number of udp queries handled at once
+	DefaultMaxUDPQueries = 1024
+
+	minUDPSize = 512
+)
+
+// ErrServerClosed is returned by Serve methods after Shutdown
+var ErrServerClosed = errors.New("server closed")
+
+// Handler answers query
+type Handler interface {
+	ServeDNS(dns.Query) (dns.Answer, error)
+}
+
+// HandlerFunc is func as Handler
+type HandlerFunc func(dns.Query) (dns.Answer, error)
+
+// ServeDNS calls f
+func (f HandlerFunc) ServeDNS(q dns.Query) (dns.Answer, error) {
+	return f(q)
+}
+
+// Server serves DNS over UDP and TCP
+type Server struct {
+	handler           Handler
+	udpSize           uint16
+	idleTimeout       time.Duration
+	maxConns          int
+	maxQueriesPerConn int
+	maxUDPQueries     int
+	// udpSem は処理中の UDP query の数を数える。
+	udpSem chan struct{}
+
+	mu        sync.Mutex
+	closing   bool
+	listeners map[net.Listener]bool
+	pconns    map[net.PacketConn]bool
+	conns     map[net.Conn]bool
+	wg        sync.WaitGroup
+}
+
+// Option is option of Server
+type Option func(*Server)
+
+// WithUDPSize sets udp payload size advertised and used
+func WithUDPSize(size uint16) Option {
+	return func(s *Server) {
+		if size < minUDPSize {
+			size = minUDPSize
+		}
+		s.udpSize = size
+	}
+}
+
+// WithIdleTimeout sets time to wait next query on tcp
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.idleTimeout = d
+	}
+}
+
+// WithMaxConns limits tcp connections at once, new ones over it are closed
+func WithMaxConns(n int) Option {
+	return func(s *Server) {
+		s.maxConns = n
+	}
+}
+
+// WithMaxQueriesPerConn limits queries on a tcp connection
+func WithMaxQueriesPerConn(n int) Option {
+	return func(s *Server) {
+		s.maxQueriesPerConn = n
+	}
+}
+
+// WithMaxUDPQueries limits udp queries handled at once, datagrams over it are dropped
+func WithMaxUDPQueries(n int) Option {
+	return func(s *Server) {
+		s.maxUDPQueries = n
+	}
+}
+
+// New is ctor of Server
+func New(handler Handler, opts ...Option) *Server {
+	s := &Server{
+		handler:           handler,
+		udpSize:           DefaultUDPSize,
+		idleTimeout:       DefaultIdleTimeout,
+		maxConns:          DefaultMaxConns,
+		maxQueriesPerConn: DefaultMaxQueriesPerConn,
+		maxUDPQueries:     DefaultMaxUDPQueries,
+		listeners:         map[net.Listener]bool{},
+		pconns:            map[net.PacketConn]bool{},
+		conns:             map[net.Conn]bool{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.udpSem = make(chan struct{}, s.maxUDPQueries)
+	return s
+}
+
+// ListenAndServe listens addr on both UDP and TCP, and serves until Shutdown
+func (s *Server) ListenAndServe(addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	// port 0 でも UDP と TCP で同じ port にする。
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		return err
+	}
+	errc := make(chan error, 2)
+	go func() { errc <- s.ServeUDP(pc) }()
+	go func() { errc <- s.ServeTCP(l) }()
+	return <-errc
+}
+
+// ListenAndServeTLS listens addr on TCP, and serves DoT until Shutdown. see RFC 7858
+func (s *Server) ListenAndServeTLS(addr string, config *tls.Config) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeTLS(l, config)
+}
+
+// ServeTLS serves DoT on connections accepted by l until Shutdown
+func (s *Server) ServeTLS(l net.Listener, config *tls.Config) error {
+	// 枠付けは TCP と同じ。handshake も idle timeout で打ち切られる。
+	return s.ServeTCP(tls.NewListener(l, config))
+}
+
+// ServeUDP serves queries from pc until Shutdown
+func (s *Server) ServeUDP(pc net.PacketConn) error {
+	if !s.track(func() { s.pconns[pc] = true }) {
+		return ErrServerClosed
+	}
+	defer s.wg.Done()
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return err
+		}
+		select {
+		case s.udpSem <- struct{}{}:
+		default:
+			// 溢れた分は捨てる。client は再送してくる。
+			continue
+		}
+		msg := make([]byte, n)
+		copy(msg, buf[:n])
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			b, ok := s.handle(msg, false)
+			<-s.udpSem
+			if !ok {
+				return
+			}
+			pc.WriteTo(b, addr)
+		}()
+	}
+}
+
+// ServeTCP serves queries from connections accepted by l until Shutdown
+func (s *Server) ServeTCP(l net.Listener) error {
+	if !s.track(func() { s.listeners[l] = true }) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.wg.Done()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return err
+		}
+		s.mu.Lock()
+		full := len(s.conns) >= s.maxConns
+		s.mu.Unlock()
+		if full {
+			conn.Close()
+			continue
+		}
+		if !s.track(func() { s.conns[conn] = true }) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// serveConn answers queries on conn one by one. see RFC 7766
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	for i := 0; i < s.maxQueriesPerConn; i++ {
+		if !s.setIdleDeadline(conn) {
+			return
+		}
+		var l [2]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return
+		}
+		msg := make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		b, ok := s.handle(msg, true)
+		if !ok {
+			return
+		}
+		binary.BigEndian.PutUint16(l[:], uint16(len(b)))
+		conn.SetWriteDeadline(time.Now().Add(s.idleTimeout))
+		if _, err := conn.Write(append(l[:], b...)); err != nil {
+			return
+		}
+	}
+}
+
+// serveDNS calls handler, a panic in it is returned as error like net/http.
+func (s *Server) serveDNS(q dns.Query) (ans dns.Answer, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in handler: %v", r)
+		}
+	}()
+	return s.handler.ServeDNS(q)
+}
+
+// handle returns packed response of msg, or false if it should be dropped.
+func (s *Server) handle(msg []byte, stream bool) (b []byte, ok bool) {
+	defer func() {
+		// 壊れた応答を pack しようとして panic したときは捨てる。
+		if r := recover(); r != nil {
+			b, ok = nil, false
+		}
+	}()
+	q, err := dns.ParseQuery(msg)
+	var ans dns.Answer
+	switch err {
+	case nil:
+		ans, err = s.serveDNS(q)
+		if err != nil {
+			ans = dns.NewErrorAnswer(q, dns.ServFail)
+		}
+		ans.ReplyTo(q)
+	case dns.ErrNotImp:
+		ans = dns.NewErrorAnswer(q, dns.NotImp)
+	default:
+		if len(msg) < 12 || msg[2]&0x80 != 0 {
+			// ID もわからないか、応答に応答してしまう。
+			return nil, false
+		}
+		ans = dns.NewErrorAnswer(q, dns.FormErr)
+	}
+	if q.EDNS0() > 0 {
+		var options []byte
+		if v, ok := q.EDNS0Option(dns.EDNS0TCPKeepalive); ok && stream {
+			if len(v) != 0 {
+				// client は timeout を送ってはいけない。 see RFC 7828 3.2.1
+				ans = dns.NewErrorAnswer(q, dns.FormErr)
+			} else {
+				options = dns.PackEDNS0Option(dns.EDNS0TCPKeepalive, s.keepalive())
+			}
+		}
+		ans.SetEDNS0(s.udpSize, options)
+	}
+	if stream {
+		b, err = ans.Pack()
+		return b, err == nil && len(b) <= 65535
+	}
+	size := minUDPSize
+	if int(q.EDNS0()) > size {
+		size = int(q.EDNS0())
+	}
+	if int(s.udpSize) < size {
+		size = int(s.udpSize)
+	}
+	b, err = ans.PackWithin(size)
+	return b, err == nil
+}
+
+// keepalive returns idle timeout in units of 100 milliseconds.
+func (s *Server) keepalive() []byte {
+	timeout := s.idleTimeout / (100 * time.Millisecond)
+	if timeout > 0xffff {
+		timeout = 0xffff
+	}
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(timeout))
+	return b
+}
+
+// track registers by add unless shutting down.
+func (s *Server) track(add func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	add()
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+func (s *Server) setIdleDeadline(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+	return true
+}
+
+// Shutdown stops accepting queries and waits in-flight ones, or closes all when ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	// 読み込み待ちだけを起こして、処理中の応答は書かせる。
+	now := time.Now()
+	for pc := range s.pconns {
+		pc.SetReadDeadline(now)
+	}
+	for conn := range s.conns {
+		conn.SetReadDeadline(now)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pc := range s.pconns {
+		pc.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	return err
+}
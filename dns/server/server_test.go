@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+)
+
+func answerA(q dns.Query) (dns.Answer, error) {
+	if dns.Same(q.Question.Name(), "panic.example.") {
+		panic("bie")
+	}
+	ans := dns.NewAnswer(q.Question)
+	n := 1
+	if dns.Same(q.Question.Name(), "big.example.") {
+		n = 30
+	}
+	for i := 0; i < n; i++ {
+		ans.Answers = append(ans.Answers, dns.NewResourceRecord(q.Question.Name(), dns.A, dns.IN, 300, []byte{192, 0, 2, byte(i)}))
+	}
+	return ans, nil
+}
+
+func start(t *testing.T, opts ...Option) (*Server, string) {
+	s := New(HandlerFunc(answerA), opts...)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServeUDP(pc)
+	go s.ServeTCP(l)
+	return s, pc.LocalAddr().String()
+}
+
+func pack(q dns.Query) []byte {
+	p := make([]byte, 512)
+	n, _ := q.Read(p)
+	return p[:n]
+}
+
+func exchangeUDP(t *testing.T, addr string, msg []byte) dns.Answer {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	ans, err := dns.ParseAnswer(buf[:n])
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	return ans
+}
+
+func exchangeTCP(t *testing.T, conn net.Conn, msg []byte) dns.Answer {
+	conn.SetDeadline(time.Now().Add(time.Second))
+	l := make([]byte, 2)
+	binary.BigEndian.PutUint16(l, uint16(len(msg)))
+	if _, err := conn.Write(append(l, msg...)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(conn, l); err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(l))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	ans, err := dns.ParseAnswer(buf)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	return ans
+}
+
+func TestServeUDP(t *testing.T) {
+	s, addr := start(t)
+	defer s.Shutdown(context.Background())
+
+	q := dns.NewQuery("www.example.", dns.A)
+	ans := exchangeUDP(t, addr, pack(q))
+	if ans.Header.ID() != q.Header.ID() || len(ans.Answers) != 1 || ans.Header.TC() {
+		t.Fatalf("unexpected answer: %v", ans)
+	}
+
+	big := dns.NewQuery("big.example.", dns.A)
+	ans = exchangeUDP(t, addr, pack(big))
+	if !ans.Header.TC() || len(ans.Answers) != 0 {
+		t.Fatalf("should be truncated: %v", ans.Header)
+	}
+	big = dns.NewQuery("big.example.", dns.A)
+	big.SetEDNS0(4096)
+	ans = exchangeUDP(t, addr, pack(big))
+	if ans.Header.TC() || len(ans.Answers) != 30 {
+		t.Fatalf("should fit with EDNS0: %v", ans.Header)
+	}
+
+	garbage := pack(dns.NewQuery("www.example.", dns.A))
+	ans = exchangeUDP(t, addr, garbage[:20])
+	if ans.Header.RCode() != dns.FormErr {
+		t.Fatalf("expected: FORMERR, but got %v", ans.Header.RCode())
+	}
+
+	ans = exchangeUDP(t, addr, pack(dns.NewQuery("panic.example.", dns.A)))
+	if ans.Header.RCode() != dns.ServFail {
+		t.Fatalf("panic in handler should be SERVFAIL: %v", ans.Header.RCode())
+	}
+	if ans = exchangeUDP(t, addr, pack(q)); len(ans.Answers) != 1 {
+		t.Fatalf("server should survive panic: %v", ans)
+	}
+}
+
+func TestMaxUDPQueries(t *testing.T) {
+	block := make(chan struct{})
+	s := New(HandlerFunc(func(q dns.Query) (dns.Answer, error) {
+		if dns.Same(q.Question.Name(), "slow.example.") {
+			<-block
+		}
+		return answerA(q)
+	}), WithMaxUDPQueries(1))
+	defer s.Shutdown(context.Background())
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServeUDP(pc)
+	addr := pc.LocalAddr().String()
+
+	slow, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	slow.Write(pack(dns.NewQuery("slow.example.", dns.A)))
+	// slow が処理中になるのを待つ。
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	conn.Write(pack(dns.NewQuery("www.example.", dns.A)))
+	if _, err := conn.Read(make([]byte, 512)); err == nil {
+		t.Fatal("query over the limit should be dropped")
+	}
+
+	close(block)
+	slow.SetDeadline(time.Now().Add(time.Second))
+	if _, err := slow.Read(make([]byte, 512)); err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if ans := exchangeUDP(t, addr, pack(dns.NewQuery("www.example.", dns.A))); len(ans.Answers) != 1 {
+		t.Fatalf("unexpected answer: %v", ans)
+	}
+}
+
+func TestServeTCP(t *testing.T) {
+	s, addr := start(t, WithMaxQueriesPerConn(2))
+	defer s.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for i := 0; i < 2; i++ {
+		big := dns.NewQuery("big.example.", dns.A)
+		ans := exchangeTCP(t, conn, pack(big))
+		if ans.Header.ID() != big.Header.ID() || len(ans.Answers) != 30 {
+			t.Fatalf("unexpected answer: %v", ans.Header)
+		}
+	}
+	// 上限に達したら閉じられる。
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("conn should be closed: %v", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	s := New(HandlerFunc(answerA))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.ServeTCP(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	exchangeTCP(t, conn, pack(dns.NewQuery("www.example.", dns.A)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("idle conns should be closed: %v", err)
+	}
+	if err := <-errc; err != ErrServerClosed {
+		t.Fatalf("expected: %v, but got %v", ErrServerClosed, err)
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	// httptest の証明書を借りる。example.com と 127.0.0.1 を含む。
+	hs := httptest.NewTLSServer(http.NotFoundHandler())
+	hs.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(hs.Certificate())
+
+	s := New(HandlerFunc(answerA), WithIdleTimeout(300*time.Millisecond))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServeTLS(l, &tls.Config{Certificates: hs.TLS.Certificates})
+	defer s.Shutdown(context.Background())
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "example.com"})
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	defer conn.Close()
+
+	q := dns.NewQuery("www.example.", dns.A)
+	q.SetEDNS0(1232)
+	q.SetEDNS0Option(dns.EDNS0TCPKeepalive, nil)
+	ans := exchangeTCP(t, conn, pack(q))
+	if len(ans.Answers) != 1 || len(ans.Additionals) != 1 {
+		t.Fatalf("unexpected answer: %v", ans)
+	}
+	// 300ms は 100ms 単位で 3。
+	expected := dns.PackEDNS0Option(dns.EDNS0TCPKeepalive, []byte{0, 3})
+	if opt := ans.Additionals[0]; opt.T != dns.OPT || !bytes.Equal(opt.Rdata, expected) {
+		t.Fatalf("expected keepalive %v, but got %v", expected, opt.Rdata)
+	}
+
+	bad := dns.NewQuery("www.example.", dns.A)
+	bad.SetEDNS0(1232)
+	bad.SetEDNS0Option(dns.EDNS0TCPKeepalive, []byte{0, 100})
+	if ans := exchangeTCP(t, conn, pack(bad)); ans.Header.RCode() != dns.FormErr {
+		t.Fatalf("expected: FORMERR, but got %v", ans.Header.RCode())
+	}
+
+	// idle timeout を過ぎると閉じられる。
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	begin := time.Now()
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("conn should be closed: %v", err)
+	}
+	if time.Since(begin) > time.Second {
+		t.Fatalf("idle timeout is not enforced: %v", time.Since(begin))
+	}
+}
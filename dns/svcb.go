@@ -0,0 +1,348 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"unicode/utf8"
+)
+
+// SvcParamKey is key of SvcParams
+type SvcParamKey uint16
+
+// see https://www.iana.org/assignments/dns-svcb/dns-svcb.xhtml
+const (
+	// KeyMandatory is mandatory
+	KeyMandatory SvcParamKey = 0
+	// KeyALPN is alpn
+	KeyALPN SvcParamKey = 1
+	// KeyNoDefaultALPN is no-default-alpn
+	KeyNoDefaultALPN SvcParamKey = 2
+	// KeyPort is port
+	KeyPort SvcParamKey = 3
+	// KeyIPv4Hint is ipv4hint
+	KeyIPv4Hint SvcParamKey = 4
+	// KeyECH is ech
+	KeyECH SvcParamKey = 5
+	// KeyIPv6Hint is ipv6hint
+	KeyIPv6Hint SvcParamKey = 6
+	// KeyDoHPath is dohpath
+	KeyDoHPath SvcParamKey = 7
+)
+
+var svcParamKeyNames = map[SvcParamKey]string{
+	KeyMandatory:     "mandatory",
+	KeyALPN:          "alpn",
+	KeyNoDefaultALPN: "no-default-alpn",
+	KeyPort:          "port",
+	KeyIPv4Hint:      "ipv4hint",
+	KeyECH:           "ech",
+	KeyIPv6Hint:      "ipv6hint",
+	KeyDoHPath:       "dohpath",
+}
+
+func (k SvcParamKey) String() string {
+	if name, ok := svcParamKeyNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("key%d", uint16(k))
+}
+
+// SvcParams is SvcParams of SVCB, values are kept in wire format
+type SvcParams map[SvcParamKey][]byte
+
+// NewSvcParams is ctor of SvcParams
+func NewSvcParams() SvcParams {
+	return SvcParams{}
+}
+
+// Keys returns keys in ascending order
+func (p SvcParams) Keys() []SvcParamKey {
+	keys := make([]SvcParamKey, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// Raw returns value of key in wire format, unknown keys are also kept
+func (p SvcParams) Raw(key SvcParamKey) ([]byte, bool) {
+	v, ok := p[key]
+	return v, ok
+}
+
+// SetRaw sets value of key in wire format
+func (p SvcParams) SetRaw(key SvcParamKey, value []byte) {
+	p[key] = value
+}
+
+// Mandatory returns mandatory keys
+func (p SvcParams) Mandatory() ([]SvcParamKey, bool) {
+	v, ok := p[KeyMandatory]
+	if !ok || len(v)%2 != 0 {
+		return nil, false
+	}
+	keys := make([]SvcParamKey, len(v)/2)
+	for i := range keys {
+		keys[i] = SvcParamKey(binary.BigEndian.Uint16(v[i*2:]))
+	}
+	return keys, true
+}
+
+// SetMandatory sets mandatory keys
+func (p SvcParams) SetMandatory(keys ...SvcParamKey) {
+	sorted := append([]SvcParamKey{}, keys...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	v := make([]byte, len(sorted)*2)
+	for i, k := range sorted {
+		binary.BigEndian.PutUint16(v[i*2:], uint16(k))
+	}
+	p[KeyMandatory] = v
+}
+
+// ALPN returns alpn ids
+func (p SvcParams) ALPN() ([]string, bool) {
+	v, ok := p[KeyALPN]
+	if !ok {
+		return nil, false
+	}
+	ids := []string{}
+	for i := 0; i < len(v); {
+		l := int(v[i])
+		if i+1+l > len(v) {
+			return nil, false
+		}
+		ids = append(ids, string(v[i+1:i+1+l]))
+		i += 1 + l
+	}
+	return ids, true
+}
+
+// SetALPN sets alpn ids
+func (p SvcParams) SetALPN(ids ...string) {
+	v := []byte{}
+	for _, id := range ids {
+		v = append(v, byte(len(id)))
+		v = append(v, id...)
+	}
+	p[KeyALPN] = v
+}
+
+// NoDefaultALPN returns whether no-default-alpn is set
+func (p SvcParams) NoDefaultALPN() bool {
+	_, ok := p[KeyNoDefaultALPN]
+	return ok
+}
+
+// SetNoDefaultALPN sets no-default-alpn
+func (p SvcParams) SetNoDefaultALPN() {
+	p[KeyNoDefaultALPN] = []byte{}
+}
+
+// Port returns port
+func (p SvcParams) Port() (uint16, bool) {
+	v, ok := p[KeyPort]
+	if !ok || len(v) != 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(v), true
+}
+
+// SetPort sets port
+func (p SvcParams) SetPort(port uint16) {
+	v := make([]byte, 2)
+	binary.BigEndian.PutUint16(v, port)
+	p[KeyPort] = v
+}
+
+func hints(v []byte, size int) []net.IP {
+	ips := make([]net.IP, 0, len(v)/size)
+	for i := 0; i+size <= len(v); i += size {
+		ips = append(ips, net.IP(append([]byte{}, v[i:i+size]...)))
+	}
+	return ips
+}
+
+// IPv4Hint returns ipv4hint
+func (p SvcParams) IPv4Hint() ([]net.IP, bool) {
+	v, ok := p[KeyIPv4Hint]
+	if !ok || len(v)%net.IPv4len != 0 {
+		return nil, false
+	}
+	return hints(v, net.IPv4len), true
+}
+
+// SetIPv4Hint sets ipv4hint
+func (p SvcParams) SetIPv4Hint(ips ...net.IP) {
+	v := []byte{}
+	for _, ip := range ips {
+		v = append(v, ip.To4()...)
+	}
+	p[KeyIPv4Hint] = v
+}
+
+// ECH returns ECHConfigList
+func (p SvcParams) ECH() ([]byte, bool) {
+	v, ok := p[KeyECH]
+	return v, ok
+}
+
+// SetECH sets ECHConfigList
+func (p SvcParams) SetECH(config []byte) {
+	p[KeyECH] = config
+}
+
+// IPv6Hint returns ipv6hint
+func (p SvcParams) IPv6Hint() ([]net.IP, bool) {
+	v, ok := p[KeyIPv6Hint]
+	if !ok || len(v)%net.IPv6len != 0 {
+		return nil, false
+	}
+	return hints(v, net.IPv6len), true
+}
+
+// SetIPv6Hint sets ipv6hint
+func (p SvcParams) SetIPv6Hint(ips ...net.IP) {
+	v := []byte{}
+	for _, ip := range ips {
+		v = append(v, ip.To16()...)
+	}
+	p[KeyIPv6Hint] = v
+}
+
+// DoHPath returns dohpath URI template
+func (p SvcParams) DoHPath() (string, bool) {
+	v, ok := p[KeyDoHPath]
+	return string(v), ok
+}
+
+// SetDoHPath sets dohpath URI template
+func (p SvcParams) SetDoHPath(template string) {
+	p[KeyDoHPath] = []byte(template)
+}
+
+// Validate checks params. see RFC 9460 section 8 and 7
+func (p SvcParams) Validate() error {
+	for _, k := range p.Keys() {
+		v := p[k]
+		switch k {
+		case KeyMandatory:
+			if len(v) == 0 || len(v)%2 != 0 {
+				return errors.New("mandatory: malformed")
+			}
+			keys, _ := p.Mandatory()
+			for i, m := range keys {
+				if m == KeyMandatory {
+					return errors.New("mandatory: must not include mandatory")
+				}
+				if i > 0 && keys[i-1] >= m {
+					return errors.New("mandatory: keys must be in strictly increasing order")
+				}
+				if _, ok := p[m]; !ok {
+					return fmt.Errorf("mandatory: %v is missing", m)
+				}
+			}
+		case KeyALPN:
+			ids, ok := p.ALPN()
+			if !ok || len(ids) == 0 {
+				return errors.New("alpn: malformed")
+			}
+			for _, id := range ids {
+				if id == "" {
+					return errors.New("alpn: empty id")
+				}
+			}
+		case KeyNoDefaultALPN:
+			if len(v) != 0 {
+				return errors.New("no-default-alpn: must be empty")
+			}
+			if _, ok := p[KeyALPN]; !ok {
+				return errors.New("no-default-alpn: requires alpn")
+			}
+		case KeyPort:
+			if len(v) != 2 {
+				return errors.New("port: malformed")
+			}
+		case KeyIPv4Hint:
+			if len(v) == 0 || len(v)%net.IPv4len != 0 {
+				return errors.New("ipv4hint: malformed")
+			}
+		case KeyIPv6Hint:
+			if len(v) == 0 || len(v)%net.IPv6len != 0 {
+				return errors.New("ipv6hint: malformed")
+			}
+		case KeyDoHPath:
+			if !utf8.Valid(v) {
+				return errors.New("dohpath: must be UTF-8")
+			}
+		}
+	}
+	return nil
+}
+
+// Pack encodes params in wire format, keys are sorted
+func (p SvcParams) Pack() []byte {
+	b := []byte{}
+	for _, k := range p.Keys() {
+		v := p[k]
+		head := make([]byte, 4)
+		binary.BigEndian.PutUint16(head, uint16(k))
+		binary.BigEndian.PutUint16(head[2:], uint16(len(v)))
+		b = append(b, head...)
+		b = append(b, v...)
+	}
+	return b
+}
+
+// ParseSvcParams decodes params in wire format
+func ParseSvcParams(data []byte) (SvcParams, error) {
+	params := SvcParams{}
+	offset := 0
+	first := true
+	var last SvcParamKey
+	for offset < len(data) {
+		if offset+4 > len(data) {
+			return nil, errors.New("SvcParams: truncated")
+		}
+		key := SvcParamKey(binary.BigEndian.Uint16(data[offset:]))
+		valLen := int(binary.BigEndian.Uint16(data[offset+2:]))
+		offset += 4
+		if offset+valLen > len(data) {
+			return nil, errors.New("SvcParams: truncated value")
+		}
+		if !first && key <= last {
+			return nil, errors.New("SvcParams: keys must be in strictly increasing order")
+		}
+		first = false
+		last = key
+		params[key] = append([]byte{}, data[offset:offset+valLen]...)
+		offset += valLen
+	}
+	return params, params.Validate()
+}
+
+// Pack encodes s as SVCB rdata
+func (s *SVCBResult) Pack() ([]byte, error) {
+	if err := s.Params.Validate(); err != nil {
+		return nil, err
+	}
+	if s.AliasMode() && len(s.Params) > 0 {
+		return nil, errors.New("AliasMode must not have SvcParams")
+	}
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, s.Priority)
+	b = append(b, PackName(s.Target)...)
+	return append(b, s.Params.Pack()...), nil
+}
+
+// ResourceRecord builds SVCB or HTTPS record of s
+func (s *SVCBResult) ResourceRecord(t QueryType, ttl uint32) (ResourceRecord, error) {
+	rdata, err := s.Pack()
+	if err != nil {
+		return ResourceRecord{}, err
+	}
+	return NewResourceRecord(s.Name, t, IN, ttl, rdata), nil
+}
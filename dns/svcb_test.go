@@ -0,0 +1,86 @@
+package dns
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSvcParamsRoundTrip(t *testing.T) {
+	p := NewSvcParams()
+	p.SetMandatory(KeyPort, KeyALPN)
+	p.SetALPN("h2", "h3")
+	p.SetPort(8443)
+	p.SetIPv4Hint(net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2"))
+	p.SetIPv6Hint(net.ParseIP("2001:db8::1"))
+	p.SetDoHPath("/dns-query{?dns}")
+	p.SetRaw(65000, []byte("raw"))
+
+	parsed, err := ParseSvcParams(p.Pack())
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	mandatory, _ := parsed.Mandatory()
+	if len(mandatory) != 2 || mandatory[0] != KeyALPN || mandatory[1] != KeyPort {
+		t.Fatalf("unexpected mandatory: %v", mandatory)
+	}
+	alpn, _ := parsed.ALPN()
+	if len(alpn) != 2 || alpn[0] != "h2" || alpn[1] != "h3" {
+		t.Fatalf("unexpected alpn: %v", alpn)
+	}
+	if port, ok := parsed.Port(); !ok || port != 8443 {
+		t.Fatalf("unexpected port: %v", port)
+	}
+	v4, _ := parsed.IPv4Hint()
+	if len(v4) != 2 || !v4[1].Equal(net.ParseIP("192.0.2.2")) {
+		t.Fatalf("unexpected ipv4hint: %v", v4)
+	}
+	v6, _ := parsed.IPv6Hint()
+	if len(v6) != 1 || !v6[0].Equal(net.ParseIP("2001:db8::1")) {
+		t.Fatalf("unexpected ipv6hint: %v", v6)
+	}
+	if path, _ := parsed.DoHPath(); path != "/dns-query{?dns}" {
+		t.Fatalf("unexpected dohpath: %v", path)
+	}
+	if raw, _ := parsed.Raw(65000); string(raw) != "raw" {
+		t.Fatalf("unknown key should be kept: %v", raw)
+	}
+}
+
+func TestParseSvcParamsInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"decreasing keys", []byte{0, 3, 0, 2, 1, 187, 0, 1, 0, 3, 2, 'h', '2'}},
+		{"duplicated keys", []byte{0, 3, 0, 2, 1, 187, 0, 3, 0, 2, 1, 187}},
+		{"mandatory missing key", []byte{0, 0, 0, 2, 0, 3}},
+		{"mandatory includes itself", []byte{0, 0, 0, 2, 0, 0}},
+		{"mandatory not sorted", []byte{0, 0, 0, 4, 0, 3, 0, 1, 0, 1, 0, 3, 2, 'h', '2', 0, 3, 0, 2, 1, 187}},
+		{"no-default-alpn without alpn", []byte{0, 2, 0, 0}},
+		{"short port", []byte{0, 3, 0, 1, 1}},
+		{"truncated", []byte{0, 3, 0, 2, 1}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ParseSvcParams(c.data); err == nil {
+				t.Fatalf("err should not be nil")
+			}
+		})
+	}
+}
+
+func TestSVCBRecord(t *testing.T) {
+	s := SVCBResult{Name: "example.com.", Priority: 1, Target: "svc.example.net.", Params: NewSvcParams()}
+	s.Params.SetALPN("h3")
+	rr, err := s.ResourceRecord(HTTPS, 300)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	parsed, err := rr.SVCB()
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if parsed.Priority != 1 || parsed.Target != "svc.example.net." {
+		t.Fatalf("unexpected record: %v", parsed)
+	}
+}
@@ -0,0 +1,282 @@
+package dns
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseSVCB parses SVCB or HTTPS rdata in presentation format,
+// e.g. `1 svc.example. alpn=h2,h3 port=8443`. see RFC 9460 section 2.1 and appendix A
+func ParseSVCB(text string) (SVCBResult, error) {
+	tokens, err := splitPresentation(text)
+	if err != nil {
+		return SVCBResult{}, err
+	}
+	if len(tokens) < 2 {
+		return SVCBResult{}, errors.New("SVCB: priority and target are required")
+	}
+	priority, err := strconv.ParseUint(tokens[0], 10, 16)
+	if err != nil {
+		return SVCBResult{}, fmt.Errorf("SVCB: bad priority %q", tokens[0])
+	}
+	target := tokens[1]
+	if target != "." {
+		if target, err = unescapeName(target); err != nil {
+			return SVCBResult{}, fmt.Errorf("SVCB: bad target: %v", err)
+		}
+	}
+	params := NewSvcParams()
+	for _, token := range tokens[2:] {
+		key, value, hasValue := token, "", false
+		if i := strings.IndexByte(token, '='); i >= 0 {
+			key, value, hasValue = token[:i], token[i+1:], true
+		}
+		k, err := parseSvcParamKey(key)
+		if err != nil {
+			return SVCBResult{}, err
+		}
+		if _, dup := params[k]; dup {
+			return SVCBResult{}, fmt.Errorf("SVCB: duplicated key %v", k)
+		}
+		raw, err := Unescape(value)
+		if err != nil {
+			return SVCBResult{}, fmt.Errorf("SVCB: %v: %v", k, err)
+		}
+		if err := params.setPresentation(k, key, raw, hasValue); err != nil {
+			return SVCBResult{}, fmt.Errorf("SVCB: %v: %v", k, err)
+		}
+	}
+	s := SVCBResult{Priority: uint16(priority), Target: target, Params: params}
+	if err := params.Validate(); err != nil {
+		return SVCBResult{}, err
+	}
+	return s, nil
+}
+
+func parseSvcParamKey(key string) (SvcParamKey, error) {
+	for k, name := range svcParamKeyNames {
+		if name == key {
+			return k, nil
+		}
+	}
+	if strings.HasPrefix(key, "key") {
+		n := key[len("key"):]
+		v, err := strconv.ParseUint(n, 10, 16)
+		if err == nil && (n == "0" || n[0] != '0') {
+			return SvcParamKey(v), nil
+		}
+	}
+	return 0, fmt.Errorf("SVCB: unknown key %q", key)
+}
+
+func (p SvcParams) setPresentation(k SvcParamKey, name string, raw []byte, hasValue bool) error {
+	if strings.HasPrefix(name, "key") {
+		// keyNNNNN の値は wire format そのもの。
+		p.SetRaw(k, raw)
+		return nil
+	}
+	if k == KeyNoDefaultALPN {
+		if hasValue {
+			return errors.New("must not have value")
+		}
+		p.SetNoDefaultALPN()
+		return nil
+	}
+	if !hasValue || len(raw) == 0 {
+		return errors.New("value is required")
+	}
+	switch k {
+	case KeyMandatory:
+		items, err := splitValueList(raw)
+		if err != nil {
+			return err
+		}
+		keys := make([]SvcParamKey, len(items))
+		seen := map[SvcParamKey]bool{}
+		for i, item := range items {
+			if keys[i], err = parseSvcParamKey(string(item)); err != nil {
+				return err
+			}
+			if seen[keys[i]] {
+				return fmt.Errorf("duplicated key %v", keys[i])
+			}
+			seen[keys[i]] = true
+		}
+		// wire format では昇順に並べる。
+		p.SetMandatory(keys...)
+	case KeyALPN:
+		items, err := splitValueList(raw)
+		if err != nil {
+			return err
+		}
+		ids := make([]string, len(items))
+		for i, item := range items {
+			if len(item) == 0 || len(item) > 255 {
+				return errors.New("bad alpn id")
+			}
+			ids[i] = string(item)
+		}
+		p.SetALPN(ids...)
+	case KeyPort:
+		port, err := strconv.ParseUint(string(raw), 10, 16)
+		if err != nil {
+			return err
+		}
+		p.SetPort(uint16(port))
+	case KeyIPv4Hint, KeyIPv6Hint:
+		ips := []net.IP{}
+		for _, s := range strings.Split(string(raw), ",") {
+			ip := net.ParseIP(s)
+			if ip == nil || (ip.To4() != nil) != (k == KeyIPv4Hint) {
+				return fmt.Errorf("bad address %q", s)
+			}
+			ips = append(ips, ip)
+		}
+		if k == KeyIPv4Hint {
+			p.SetIPv4Hint(ips...)
+		} else {
+			p.SetIPv6Hint(ips...)
+		}
+	case KeyECH:
+		config, err := base64.StdEncoding.DecodeString(string(raw))
+		if err != nil {
+			return err
+		}
+		p.SetECH(config)
+	case KeyDoHPath:
+		p.SetDoHPath(string(raw))
+	}
+	return nil
+}
+
+func (s SVCBResult) String() string {
+	target := s.Target
+	if target != "." {
+		target = Escape([]byte(target))
+	}
+	ss := []string{strconv.Itoa(int(s.Priority)), target}
+	if params := s.Params.String(); params != "" {
+		ss = append(ss, params)
+	}
+	return strings.Join(ss, " ")
+}
+
+func (p SvcParams) String() string {
+	ss := []string{}
+	for _, k := range p.Keys() {
+		v := p[k]
+		switch k {
+		case KeyMandatory:
+			keys, _ := p.Mandatory()
+			names := make([]string, len(keys))
+			for i, m := range keys {
+				names[i] = m.String()
+			}
+			ss = append(ss, k.String()+"="+strings.Join(names, ","))
+		case KeyALPN:
+			ids, _ := p.ALPN()
+			items := make([]string, len(ids))
+			for i, id := range ids {
+				items[i] = Escape([]byte(escapeValueListItem(id)))
+			}
+			ss = append(ss, k.String()+"="+strings.Join(items, ","))
+		case KeyNoDefaultALPN:
+			ss = append(ss, k.String())
+		case KeyPort:
+			port, _ := p.Port()
+			ss = append(ss, fmt.Sprintf("%v=%d", k, port))
+		case KeyIPv4Hint, KeyIPv6Hint:
+			size := net.IPv4len
+			if k == KeyIPv6Hint {
+				size = net.IPv6len
+			}
+			addrs := []string{}
+			for _, ip := range hints(v, size) {
+				addrs = append(addrs, ip.String())
+			}
+			ss = append(ss, k.String()+"="+strings.Join(addrs, ","))
+		case KeyECH:
+			ss = append(ss, k.String()+"="+base64.StdEncoding.EncodeToString(v))
+		case KeyDoHPath:
+			ss = append(ss, k.String()+"="+Escape(v))
+		default:
+			if len(v) == 0 {
+				ss = append(ss, k.String())
+				continue
+			}
+			ss = append(ss, k.String()+"="+Escape(v))
+		}
+	}
+	return strings.Join(ss, " ")
+}
+
+// splitPresentation splits s by spaces out of quotes, escapes are kept as is.
+func splitPresentation(s string) ([]string, error) {
+	tokens := []string{}
+	var cur strings.Builder
+	inQuote := false
+	inToken := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(s) {
+				return nil, errors.New("dangling backslash")
+			}
+			cur.WriteByte(c)
+			cur.WriteByte(s[i+1])
+			i++
+			inToken = true
+		case c == '"':
+			inQuote = !inQuote
+			inToken = true
+		case !inQuote && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			if inToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+		default:
+			cur.WriteByte(c)
+			inToken = true
+		}
+	}
+	if inQuote {
+		return nil, errors.New("unterminated quote")
+	}
+	if inToken {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+// splitValueList splits unescaped value by ',' which is not escaped. see RFC 9460 appendix A.1
+func splitValueList(b []byte) ([][]byte, error) {
+	items := [][]byte{}
+	cur := []byte{}
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			if i+1 >= len(b) {
+				return nil, errors.New("dangling backslash")
+			}
+			cur = append(cur, b[i+1])
+			i++
+		case ',':
+			items = append(items, cur)
+			cur = []byte{}
+		default:
+			cur = append(cur, b[i])
+		}
+	}
+	return append(items, cur), nil
+}
+
+func escapeValueListItem(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, ",", `\,`, -1)
+}
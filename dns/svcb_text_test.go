@@ -0,0 +1,87 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSVCBPresentationRoundTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"alias", "0 foo.example.com.", "0 foo.example.com."},
+		{"service", "1 .", "1 ."},
+		{"port", "16 foo.example.com. port=53", "16 foo.example.com. port=53"},
+		{"generic key", `1 foo.example.com. key667=hello`, `1 foo.example.com. key667=hello`},
+		{"generic key quoted", `1 foo.example.com. key667="hello\210qoo"`, `1 foo.example.com. key667=hello\210qoo`},
+		{"hints", `1 foo.example.com. ipv6hint="2001:db8::1,2001:db8::53:1"`, `1 foo.example.com. ipv6hint=2001:db8::1,2001:db8::53:1`},
+		{"mandatory sorted", `16 foo.example.org. alpn=h2,h3-19 mandatory=ipv4hint,alpn ipv4hint=192.0.2.1`, `16 foo.example.org. mandatory=alpn,ipv4hint alpn=h2,h3-19 ipv4hint=192.0.2.1`},
+		{"escaped alpn", `16 foo.example.org. alpn="f\\\\oo\\,bar,h2"`, `16 foo.example.org. alpn=f\\\\oo\\,bar,h2`},
+		{"no-default-alpn", `1 svc.example. alpn=h2,h3 no-default-alpn port=8443 ipv4hint=192.0.2.1`, `1 svc.example. alpn=h2,h3 no-default-alpn port=8443 ipv4hint=192.0.2.1`},
+		{"dohpath", `1 dns.example. alpn=h2 dohpath=/dns-query{?dns}`, `1 dns.example. alpn=h2 dohpath=/dns-query{?dns}`},
+		{"escaped target", `1 \102oo\032bar.example.`, `1 foo\032bar.example.`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := ParseSVCB(c.text)
+			if err != nil {
+				t.Fatalf("err should be nil: %v", err)
+			}
+			if s.String() != c.expected {
+				t.Fatalf("expected: %v, but got %v", c.expected, s.String())
+			}
+			s.Name = "example.com."
+			rr, err := s.ResourceRecord(SVCB, 300)
+			if err != nil {
+				t.Fatalf("err should be nil: %v", err)
+			}
+			wire, err := rr.SVCB()
+			if err != nil {
+				t.Fatalf("err should be nil: %v", err)
+			}
+			if wire.String() != c.expected {
+				t.Fatalf("expected: %v, but got %v", c.expected, wire.String())
+			}
+		})
+	}
+}
+
+func TestSVCBEscapedALPN(t *testing.T) {
+	s, err := ParseSVCB(`16 foo.example.org. alpn="f\\\\oo\\,bar,h2"`)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	alpn, _ := s.Params.ALPN()
+	if len(alpn) != 2 || alpn[0] != `f\oo,bar` || alpn[1] != "h2" {
+		t.Fatalf("unexpected alpn: %q", alpn)
+	}
+}
+
+func TestParseSVCBInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+	}{
+		{"no target", "1"},
+		{"bad priority", "65536 foo.example.com."},
+		{"duplicated key", "1 foo.example.com. port=1 port=2"},
+		{"unknown key", "1 foo.example.com. bar=1"},
+		{"leading zero", "1 foo.example.com. key0667=1"},
+		{"missing mandatory", "1 foo.example.com. mandatory=port"},
+		{"no-default-alpn with value", "1 foo.example.com. alpn=h2 no-default-alpn=1"},
+		{"v6 in ipv4hint", "1 foo.example.com. ipv4hint=2001:db8::1"},
+		{"unterminated quote", `1 foo.example.com. alpn="h2`},
+		{"dot in target label", `1 foo\.bar.example.`},
+		{"long target label", "1 " + strings.Repeat("a", 64) + ".example."},
+		{"long target", "1 " + strings.Repeat(strings.Repeat("a", 63)+".", 4)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := ParseSVCB(c.text); err == nil {
+				t.Fatalf("err should not be nil")
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package dns
 import (
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -14,17 +16,42 @@ const (
 	CNAME = 5
 	// SOA is RR type SOA
 	SOA = 6
+	// PTR is RR type PTR
+	PTR = 12
+	// MX is RR type MX
+	MX = 15
+	// TXT is RR type TXT
+	TXT = 16
 	// AAAA is RR type AAAA
 	AAAA = 28
-	// SVCB is
+	// DNAME is RR type DNAME
+	DNAME = 39
+	// OPT is RR type OPT
+	OPT = 41
+	// SVCB is RR type SVCB
 	SVCB = 64
-	// HTTPS is
+	// HTTPS is RR type HTTPS
 	HTTPS = 65
 
 	// IN is IN
 	IN = 1
 )
 
+const (
+	// NoError is RCODE NoError
+	NoError = 0
+	// FormErr is RCODE FormErr
+	FormErr = 1
+	// ServFail is RCODE ServFail
+	ServFail = 2
+	// NXDomain is RCODE NXDomain
+	NXDomain = 3
+	// NotImp is RCODE NotImp
+	NotImp = 4
+	// Refused is RCODE Refused
+	Refused = 5
+)
+
 // QueryType is query type
 type QueryType int
 
@@ -41,15 +68,43 @@ type Result interface {
 	ShowQueryType
 }
 
+// AddressRecord is addr with its TTL
+type AddressRecord struct {
+	IP  net.IP
+	TTL uint32
+}
+
+type addressSet struct {
+	name    string
+	records []AddressRecord
+}
+
 // AResult is result of A
 type AResult struct {
-	ip net.IP
+	addressSet
+}
+
+// AAAAResult is result of AAAA
+type AAAAResult struct {
+	addressSet
+}
+
+// PTRResult is result of PTR
+type PTRResult struct {
+	names []string
 }
 
+// SVCBResult is result of SVCB or HTTPS
 type SVCBResult struct {
-	Priority int
+	Name     string
+	Priority uint16
 	Target   string
-	Params   map[int]string
+	Params   SvcParams
+}
+
+// AliasMode decides whether s is AliasMode
+func (s *SVCBResult) AliasMode() bool {
+	return s.Priority == 0
 }
 
 func (q QueryType) String() string {
@@ -62,24 +117,80 @@ func (q QueryType) String() string {
 		return "CNAME"
 	case SOA:
 		return "SOA"
+	case PTR:
+		return "PTR"
+	case MX:
+		return "MX"
+	case TXT:
+		return "TXT"
 	case AAAA:
 		return "AAAA"
+	case DNAME:
+		return "DNAME"
+	case OPT:
+		return "OPT"
 	case SVCB:
 		return "SVCB"
 	case HTTPS:
 		return "HTTPS"
 	default:
-		return fmt.Sprintf("unknown(%d)", q)
+		return fmt.Sprintf("TYPE%d", q)
 	}
 }
 
+var knownTypes = []QueryType{A, NS, CNAME, SOA, PTR, MX, TXT, AAAA, DNAME, OPT, SVCB, HTTPS}
+
+// ParseQueryType parses type name like "AAAA" or "TYPE28". see RFC 3597
+func ParseQueryType(s string) (QueryType, bool) {
+	s = strings.ToUpper(s)
+	for _, t := range knownTypes {
+		if t.String() == s {
+			return t, true
+		}
+	}
+	if strings.HasPrefix(s, "TYPE") {
+		n, err := strconv.ParseUint(s[len("TYPE"):], 10, 16)
+		if err == nil {
+			return QueryType(n), true
+		}
+	}
+	return 0, false
+}
+
 func (c Class) String() string {
 	switch c {
 	case IN:
 		return "IN"
 	default:
-		return fmt.Sprintf("unknown(%d)", c)
+		return fmt.Sprintf("CLASS%d", c)
+	}
+}
+
+// Name returns canonical name which has the addrs
+func (s *addressSet) Name() string {
+	return s.name
+}
+
+// IP returns the first addr
+func (s *addressSet) IP() net.IP {
+	if len(s.records) == 0 {
+		return nil
+	}
+	return s.records[0].IP
+}
+
+// IPs returns all addrs
+func (s *addressSet) IPs() []net.IP {
+	ips := make([]net.IP, len(s.records))
+	for i, r := range s.records {
+		ips[i] = r.IP
 	}
+	return ips
+}
+
+// Records returns all addrs with TTL
+func (s *addressSet) Records() []AddressRecord {
+	return s.records
 }
 
 // Type returns query type
@@ -87,14 +198,38 @@ func (a *AResult) Type() QueryType {
 	return A
 }
 
-// IP returns result
-func (a *AResult) IP() net.IP {
-	return a.ip
-}
-
 // NewAResult is AResult ctor
-func NewAResult(ip net.IP) AResult {
+func NewAResult(name string, records []AddressRecord) AResult {
 	return AResult{
-		ip: ip,
+		addressSet{name: name, records: records},
+	}
+}
+
+// Type returns query type
+func (a *AAAAResult) Type() QueryType {
+	return AAAA
+}
+
+// NewAAAAResult is AAAAResult ctor
+func NewAAAAResult(name string, records []AddressRecord) AAAAResult {
+	return AAAAResult{
+		addressSet{name: name, records: records},
+	}
+}
+
+// Type returns query type
+func (p *PTRResult) Type() QueryType {
+	return PTR
+}
+
+// Names returns names of the addr
+func (p *PTRResult) Names() []string {
+	return p.names
+}
+
+// NewPTRResult is PTRResult ctor
+func NewPTRResult(names []string) PTRResult {
+	return PTRResult{
+		names: names,
 	}
 }
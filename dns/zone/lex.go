@@ -0,0 +1,125 @@
+package zone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nna774/zorori/dns"
+)
+
+type lexError struct {
+	line int
+	msg  string
+}
+
+func (e *lexError) Error() string {
+	return pos("", e.line) + ": " + e.msg
+}
+
+// entry is a logical line of master file, joined across parentheses.
+type entry struct {
+	line int
+	// blankOwner は行頭が空白で owner が省略されていること。
+	blankOwner bool
+	tokens     []string
+}
+
+// lex splits master file into entries. see RFC 1035 5.1
+// tokens keep quotes and escapes as is, they are decoded by each field.
+func lex(src string) ([]entry, error) {
+	entries := []entry{}
+	cur := entry{line: 1}
+	var token strings.Builder
+	inToken := false
+	quoted := false
+	depth := 0
+	line := 1
+	atLineStart := true
+
+	flush := func() {
+		if inToken {
+			cur.tokens = append(cur.tokens, token.String())
+			token.Reset()
+			inToken = false
+		}
+	}
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if atLineStart && depth == 0 {
+			cur = entry{line: line, blankOwner: c == ' ' || c == '\t'}
+			atLineStart = false
+		}
+		switch {
+		case c == '\\':
+			if i+1 >= len(src) || src[i+1] == '\n' {
+				return nil, &lexError{line: line, msg: "bad escape"}
+			}
+			token.WriteByte(c)
+			token.WriteByte(src[i+1])
+			inToken = true
+			i++
+		case c == '"':
+			token.WriteByte(c)
+			inToken = true
+			quoted = !quoted
+		case quoted:
+			if c == '\n' {
+				return nil, &lexError{line: line, msg: "unterminated quote"}
+			}
+			token.WriteByte(c)
+		case c == ';':
+			for i+1 < len(src) && src[i+1] != '\n' {
+				i++
+			}
+		case c == '(':
+			flush()
+			depth++
+		case c == ')':
+			flush()
+			if depth == 0 {
+				return nil, &lexError{line: line, msg: "unbalanced parenthesis"}
+			}
+			depth--
+		case c == ' ' || c == '\t' || c == '\r':
+			flush()
+		case c == '\n':
+			flush()
+			if depth == 0 {
+				if len(cur.tokens) > 0 {
+					entries = append(entries, cur)
+				}
+				atLineStart = true
+			}
+			line++
+		default:
+			token.WriteByte(c)
+			inToken = true
+		}
+	}
+	if quoted {
+		return nil, &lexError{line: line, msg: "unterminated quote"}
+	}
+	if depth > 0 {
+		return nil, &lexError{line: cur.line, msg: "unbalanced parenthesis"}
+	}
+	flush()
+	if !atLineStart && len(cur.tokens) > 0 {
+		entries = append(entries, cur)
+	}
+	return entries, nil
+}
+
+// characterString decodes token as <character-string>, quoted or not.
+func characterString(token string) ([]byte, error) {
+	if len(token) >= 2 && token[0] == '"' && token[len(token)-1] == '"' {
+		token = token[1 : len(token)-1]
+	}
+	b, err := dns.Unescape(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > 255 {
+		return nil, fmt.Errorf("character-string is too long")
+	}
+	return b, nil
+}
@@ -0,0 +1,585 @@
+package zone
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nna774/zorori/dns"
+)
+
+const (
+	maxIncludeDepth = 8
+	// maxGenerate limits records made by a $GENERATE.
+	maxGenerate = 65536
+)
+
+// Load reads zone file at path, origin may be empty if the file has $ORIGIN
+func Load(path, origin string) (*Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f, path, origin)
+}
+
+// Parse reads zone file in RFC 1035 master file format, $INCLUDE is relative to the working directory.
+// only class IN is supported, and a label must not contain '.' as names are held as dotted strings.
+func Parse(r io.Reader, origin string) (*Zone, error) {
+	return parse(r, "", origin)
+}
+
+func parse(r io.Reader, path, origin string) (*Zone, error) {
+	p := &parser{origin: origin}
+	if err := p.parseFile(r, path, 0); err != nil {
+		return nil, err
+	}
+	if p.zone == nil {
+		return nil, fmt.Errorf("no SOA")
+	}
+	return p.zone, nil
+}
+
+type parser struct {
+	zone       *Zone
+	origin     string
+	ttl        uint32
+	hasTTL     bool
+	lastTTL    uint32
+	hasLastTTL bool
+	owner      string
+}
+
+// pos shows where the entry is for error messages.
+func pos(path string, line int) string {
+	if path == "" {
+		return fmt.Sprintf("line %d", line)
+	}
+	return fmt.Sprintf("%v:%d", path, line)
+}
+
+func (p *parser) parseFile(r io.Reader, path string, depth int) error {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	entries, err := lex(string(src))
+	if err != nil {
+		if e, ok := err.(*lexError); ok {
+			return fmt.Errorf("%v: %v", pos(path, e.line), e.msg)
+		}
+		return err
+	}
+	for _, e := range entries {
+		if err := p.parseEntry(e, path, depth); err != nil {
+			return fmt.Errorf("%v: %v", pos(path, e.line), err)
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseEntry(e entry, path string, depth int) error {
+	tokens := e.tokens
+	switch strings.ToUpper(tokens[0]) {
+	case "$ORIGIN":
+		if len(tokens) != 2 {
+			return fmt.Errorf("$ORIGIN needs a name")
+		}
+		origin, err := p.name(tokens[1])
+		if err != nil {
+			return err
+		}
+		p.origin = origin
+		return nil
+	case "$TTL":
+		if len(tokens) != 2 {
+			return fmt.Errorf("$TTL needs a ttl")
+		}
+		ttl, err := parseTTL(tokens[1])
+		if err != nil {
+			return err
+		}
+		p.ttl, p.hasTTL = ttl, true
+		return nil
+	case "$INCLUDE":
+		return p.include(tokens[1:], path, depth)
+	case "$GENERATE":
+		return p.generate(tokens[1:])
+	}
+
+	if !e.blankOwner {
+		owner, err := p.name(tokens[0])
+		if err != nil {
+			return err
+		}
+		p.owner = owner
+		tokens = tokens[1:]
+	}
+	if p.owner == "" {
+		return fmt.Errorf("no owner")
+	}
+	rr, err := p.parseRecord(p.owner, tokens)
+	if err != nil {
+		return err
+	}
+	return p.add(rr)
+}
+
+func (p *parser) add(rr dns.ResourceRecord) error {
+	if p.zone == nil {
+		if rr.T != dns.SOA {
+			return fmt.Errorf("first record must be SOA")
+		}
+		p.zone = New(rr.Name)
+	}
+	return p.zone.Add(rr)
+}
+
+// include parses file [origin], the origin of the includer does not change. see RFC 1035 5.1
+func (p *parser) include(args []string, path string, depth int) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("$INCLUDE needs a file and optional origin")
+	}
+	if depth >= maxIncludeDepth {
+		return fmt.Errorf("$INCLUDE is too deep")
+	}
+	file := args[0]
+	if !filepath.IsAbs(file) && path != "" {
+		file = filepath.Join(filepath.Dir(path), file)
+	}
+	saved := p.origin
+	defer func() { p.origin = saved }()
+	if len(args) == 2 {
+		origin, err := p.name(args[1])
+		if err != nil {
+			return err
+		}
+		p.origin = origin
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return p.parseFile(f, file, depth+1)
+}
+
+// generate expands $GENERATE range lhs [ttl] [class] type rhs, as BIND does.
+func (p *parser) generate(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("$GENERATE needs range, lhs, type and rhs")
+	}
+	start, stop, step, err := parseRange(args[0])
+	if err != nil {
+		return err
+	}
+	if (stop-start)/step >= maxGenerate {
+		return fmt.Errorf("$GENERATE makes too many records: %v", args[0])
+	}
+	for i := start; i <= stop; i += step {
+		tokens := make([]string, len(args)-1)
+		for j, arg := range args[1:] {
+			s, err := substitute(arg, i)
+			if err != nil {
+				return err
+			}
+			tokens[j] = s
+		}
+		owner, err := p.name(tokens[0])
+		if err != nil {
+			return err
+		}
+		rr, err := p.parseRecord(owner, tokens[1:])
+		if err != nil {
+			return err
+		}
+		if err := p.add(rr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRange parses start-stop[/step].
+func parseRange(s string) (int, int, int, error) {
+	step := 1
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		n, err := strconv.Atoi(s[i+1:])
+		if err != nil || n <= 0 {
+			return 0, 0, 0, fmt.Errorf("bad range %v", s)
+		}
+		step = n
+		s = s[:i]
+	}
+	bounds := strings.SplitN(s, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, 0, fmt.Errorf("bad range %v", s)
+	}
+	start, err1 := strconv.Atoi(bounds[0])
+	stop, err2 := strconv.Atoi(bounds[1])
+	if err1 != nil || err2 != nil || start < 0 || stop < start {
+		return 0, 0, 0, fmt.Errorf("bad range %v", s)
+	}
+	return start, stop, step, nil
+}
+
+// substitute replaces $ and ${offset,width,base} by i, \$ is literal $.
+func substitute(s string, i int) (string, error) {
+	var b strings.Builder
+	for k := 0; k < len(s); k++ {
+		switch {
+		case s[k] == '\\' && k+1 < len(s) && s[k+1] == '$':
+			b.WriteByte('$')
+			k++
+		case s[k] == '\\' && k+1 < len(s):
+			b.WriteString(s[k : k+2])
+			k++
+		case s[k] == '$' && k+1 < len(s) && s[k+1] == '{':
+			end := strings.IndexByte(s[k:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("bad modifier in %v", s)
+			}
+			v, err := modify(s[k+2:k+end], i)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			k += end
+		case s[k] == '$':
+			b.WriteString(strconv.Itoa(i))
+		default:
+			b.WriteByte(s[k])
+		}
+	}
+	return b.String(), nil
+}
+
+func modify(modifier string, i int) (string, error) {
+	parts := strings.Split(modifier, ",")
+	if len(parts) > 3 {
+		return "", fmt.Errorf("bad modifier %v", modifier)
+	}
+	offset, width, base := 0, 0, "d"
+	var err error
+	if offset, err = strconv.Atoi(parts[0]); err != nil {
+		return "", fmt.Errorf("bad modifier %v", modifier)
+	}
+	if len(parts) >= 2 {
+		if width, err = strconv.Atoi(parts[1]); err != nil || width < 0 {
+			return "", fmt.Errorf("bad modifier %v", modifier)
+		}
+	}
+	if len(parts) == 3 {
+		base = parts[2]
+	}
+	var format string
+	switch base {
+	case "d":
+		format = "%0*d"
+	case "o":
+		format = "%0*o"
+	case "x":
+		format = "%0*x"
+	case "X":
+		format = "%0*X"
+	default:
+		return "", fmt.Errorf("bad base %v", base)
+	}
+	return fmt.Sprintf(format, width, i+offset), nil
+}
+
+// parseRecord parses [ttl] [class] type rdata... in any order of ttl and class.
+func (p *parser) parseRecord(owner string, fields []string) (dns.ResourceRecord, error) {
+	ttl, hasTTL := p.ttl, p.hasTTL
+	if !hasTTL {
+		ttl, hasTTL = p.lastTTL, p.hasLastTTL
+	}
+	for len(fields) > 0 {
+		if class, ok := parseClass(fields[0]); ok {
+			if class != dns.IN {
+				return dns.ResourceRecord{}, fmt.Errorf("class %v is not supported", fields[0])
+			}
+			fields = fields[1:]
+			continue
+		}
+		if v, err := parseTTL(fields[0]); err == nil {
+			ttl, hasTTL = v, true
+			p.lastTTL, p.hasLastTTL = v, true
+			fields = fields[1:]
+			continue
+		}
+		break
+	}
+	if len(fields) == 0 {
+		return dns.ResourceRecord{}, fmt.Errorf("no type")
+	}
+	t, ok := dns.ParseQueryType(fields[0])
+	if !ok {
+		return dns.ResourceRecord{}, fmt.Errorf("unknown type %v", fields[0])
+	}
+	rdata, err := p.parseRdata(t, fields[1:])
+	if err != nil {
+		return dns.ResourceRecord{}, fmt.Errorf("%v: %v", t, err)
+	}
+	if t == dns.SOA && !hasTTL {
+		// TTL が無ければ SOA の minimum を使う。
+		ttl, hasTTL = binary.BigEndian.Uint32(rdata[len(rdata)-4:]), true
+	}
+	if !hasTTL {
+		return dns.ResourceRecord{}, fmt.Errorf("no TTL")
+	}
+	return dns.NewResourceRecord(owner, t, dns.IN, ttl, rdata), nil
+}
+
+// parseClass parses class mnemonic or CLASSnnn. see RFC 3597 5
+func parseClass(s string) (dns.Class, bool) {
+	s = strings.ToUpper(s)
+	switch s {
+	case "IN":
+		return dns.IN, true
+	case "CS":
+		return 2, true
+	case "CH":
+		return 3, true
+	case "HS":
+		return 4, true
+	}
+	if !strings.HasPrefix(s, "CLASS") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[len("CLASS"):], 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return dns.Class(n), true
+}
+
+func (p *parser) parseRdata(t dns.QueryType, fields []string) ([]byte, error) {
+	if len(fields) > 0 && fields[0] == `\#` {
+		return parseGeneric(fields[1:])
+	}
+	switch t {
+	case dns.A:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("needs an address")
+		}
+		ip := net.ParseIP(fields[0]).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("bad address %v", fields[0])
+		}
+		return ip, nil
+	case dns.AAAA:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("needs an address")
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("bad address %v", fields[0])
+		}
+		return ip.To16(), nil
+	case dns.NS, dns.CNAME, dns.DNAME, dns.PTR:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("needs a name")
+		}
+		name, err := p.name(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		return dns.PackName(name), nil
+	case dns.MX:
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("needs preference and exchange")
+		}
+		pref, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("bad preference %v", fields[0])
+		}
+		name, err := p.name(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		rdata := make([]byte, 2)
+		binary.BigEndian.PutUint16(rdata, uint16(pref))
+		return append(rdata, dns.PackName(name)...), nil
+	case dns.TXT:
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("needs strings")
+		}
+		rdata := []byte{}
+		for _, f := range fields {
+			s, err := characterString(f)
+			if err != nil {
+				return nil, err
+			}
+			rdata = append(append(rdata, byte(len(s))), s...)
+		}
+		return rdata, nil
+	case dns.SOA:
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("needs mname rname serial refresh retry expire minimum")
+		}
+		rdata := []byte{}
+		for _, f := range fields[:2] {
+			name, err := p.name(f)
+			if err != nil {
+				return nil, err
+			}
+			rdata = append(rdata, dns.PackName(name)...)
+		}
+		for i, f := range fields[2:] {
+			var v uint32
+			var err error
+			if i == 0 {
+				var n uint64
+				n, err = strconv.ParseUint(f, 10, 32)
+				v = uint32(n)
+			} else {
+				v, err = parseTTL(f)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("bad number %v", f)
+			}
+			var b [4]byte
+			binary.BigEndian.PutUint32(b[:], v)
+			rdata = append(rdata, b[:]...)
+		}
+		return rdata, nil
+	case dns.SVCB, dns.HTTPS:
+		if len(fields) >= 2 && fields[1] != "." {
+			target, err := p.name(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			fields = append([]string{fields[0], target}, fields[2:]...)
+		}
+		s, err := dns.ParseSVCB(strings.Join(fields, " "))
+		if err != nil {
+			return nil, err
+		}
+		return s.Pack()
+	default:
+		return nil, fmt.Errorf("not supported, use \\# form")
+	}
+}
+
+// parseGeneric parses \# length hex... see RFC 3597 5
+func parseGeneric(fields []string) ([]byte, error) {
+	if len(fields) < 1 {
+		return nil, fmt.Errorf("needs length")
+	}
+	n, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("bad length %v", fields[0])
+	}
+	rdata, err := hex.DecodeString(strings.Join(fields[1:], ""))
+	if err != nil {
+		return nil, err
+	}
+	if len(rdata) != int(n) {
+		return nil, fmt.Errorf("length is %v, but rdata is %v bytes", n, len(rdata))
+	}
+	return rdata, nil
+}
+
+// name makes token absolute by origin, decoding escapes.
+// names are held as dotted strings, so '.' in a label (\. or \046) is rejected.
+func (p *parser) name(token string) (string, error) {
+	if token == "@" {
+		if p.origin == "" {
+			return "", fmt.Errorf("no origin")
+		}
+		return dns.Fqdn(p.origin), nil
+	}
+	labels := []string{}
+	var label strings.Builder
+	absolute := false
+	for i := 0; i < len(token); i++ {
+		switch {
+		case token[i] == '\\':
+			end := i + 2
+			if i+1 < len(token) && token[i+1] >= '0' && token[i+1] <= '9' {
+				end = i + 4
+			}
+			if end > len(token) {
+				return "", fmt.Errorf("bad escape in %v", token)
+			}
+			b, err := dns.Unescape(token[i:end])
+			if err != nil {
+				return "", err
+			}
+			if b[0] == '.' {
+				return "", fmt.Errorf("dot in label is not supported: %v", token)
+			}
+			label.Write(b)
+			i = end - 1
+		case token[i] == '.':
+			if label.Len() == 0 {
+				if i == len(token)-1 && i == 0 {
+					// root
+					absolute = true
+					continue
+				}
+				return "", fmt.Errorf("empty label in %v", token)
+			}
+			labels = append(labels, label.String())
+			label.Reset()
+			absolute = i == len(token)-1
+		default:
+			label.WriteByte(token[i])
+		}
+	}
+	if label.Len() > 0 {
+		labels = append(labels, label.String())
+	}
+	for _, l := range labels {
+		if len(l) > 63 {
+			return "", fmt.Errorf("label is too long in %v", token)
+		}
+	}
+	name := strings.Join(labels, ".")
+	if !absolute {
+		if p.origin == "" {
+			return "", fmt.Errorf("relative name %v without origin", token)
+		}
+		name = name + "." + strings.TrimSuffix(dns.Fqdn(p.origin), ".")
+	}
+	name = dns.Fqdn(name)
+	if len(name) > 255 {
+		return "", fmt.Errorf("name is too long: %v", token)
+	}
+	return name, nil
+}
+
+// parseTTL parses ttl in seconds, or with units like 1h30m.
+func parseTTL(s string) (uint32, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	var total, n uint64
+	digits := false
+	for _, c := range strings.ToLower(s) {
+		if c >= '0' && c <= '9' {
+			n = n*10 + uint64(c-'0')
+			digits = true
+			continue
+		}
+		unit := map[rune]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}[c]
+		if unit == 0 || !digits {
+			return 0, fmt.Errorf("bad ttl %v", s)
+		}
+		total += n * unit
+		n, digits = 0, false
+	}
+	if digits || total > 0xffffffff {
+		return 0, fmt.Errorf("bad ttl %v", s)
+	}
+	return uint32(total), nil
+}
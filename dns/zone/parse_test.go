@@ -0,0 +1,140 @@
+package zone
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nna774/zorori/dns"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("example.com.zone", func(t *testing.T) {
+		z := load(t)
+		if z.Origin() != "example.com." {
+			t.Fatalf("expected: example.com., but got %v", z.Origin())
+		}
+		soa, ok := z.SOA()
+		if !ok || soa.TTL != 3600 {
+			t.Fatalf("unexpected SOA: %v", soa)
+		}
+		www := z.Records("WWW.example.com.")
+		if len(www) != 1 || www[0].TTL != 300 {
+			t.Fatalf("unexpected www: %v", www)
+		}
+		if target, _ := www[0].CNAMETO(); target != "example.com." {
+			t.Fatalf("@ should be origin: %v", target)
+		}
+		https := z.Records("_https.example.com.")
+		if s, err := https[0].SVCB(); err != nil || s.Target != "." {
+			t.Fatalf("unexpected HTTPS: %v, %v", s, err)
+		}
+	})
+	t.Run("full.zone", func(t *testing.T) {
+		z, err := Load("testdata/full.zone", "")
+		if err != nil {
+			t.Fatalf("err should be nil: %v", err)
+		}
+		soa, _ := z.SOA()
+		if soa.TTL != 86400 || soa.ShowRdata(dns.SOA) != "ns1.example.org. hostmaster.example.org. 2024010101 7200 900 604800 300" {
+			t.Fatalf("unexpected SOA: %v", soa)
+		}
+		if expected := []byte{0, 0, 0x1c, 0x20, 0, 0, 3, 0x84, 0, 0x09, 0x3a, 0x80, 0, 0, 1, 0x2c}; !bytes.Equal(soa.Rdata[len(soa.Rdata)-16:], expected) {
+			t.Fatalf("unexpected SOA timers: %v", soa.Rdata)
+		}
+		cases := []struct {
+			name  string
+			t     dns.QueryType
+			rdata string
+			ttl   uint32
+		}{
+			{"example.org.", dns.MX, "10 mail.example.org.", 86400},
+			{"ns1.example.org.", dns.A, "192.0.2.53", 3600},
+			{"txt.example.org.", dns.TXT, `"hello; world" "quote \" and \\" "unquoted"`, 86400},
+			{"esc.example.org.", dns.TXT, `"Hello"`, 86400},
+			{"sp ace.example.org.", dns.A, "192.0.2.80", 86400},
+			{"host-2.example.org.", dns.A, "192.0.2.12", 86400},
+			{"004.gen.example.org.", dns.CNAME, "host-1.example.org.", 86400},
+			{"www.sub.example.org.", dns.A, "192.0.2.200", 86400},
+			{"sub.example.org.", dns.TXT, `"included"`, 86400},
+			{"after.example.org.", dns.A, "192.0.2.99", 86400},
+		}
+		for _, c := range cases {
+			rrs := filter(z.Records(c.name), c.t)
+			if len(rrs) != 1 {
+				t.Fatalf("%v %v should be 1 record: %v", c.name, c.t, rrs)
+			}
+			if got := rrs[0].ShowRdata(c.t); got != c.rdata || rrs[0].TTL != c.ttl {
+				t.Fatalf("expected: %v (%v), but got %v (%v)", c.rdata, c.ttl, got, rrs[0].TTL)
+			}
+		}
+		if len(filter(z.Records("002.gen.example.org."), dns.CNAME)) != 1 || len(z.Records("001.gen.example.org.")) != 0 {
+			t.Fatalf("$GENERATE step is not respected")
+		}
+		raw := filter(z.Records("raw.example.org."), dns.QueryType(65534))
+		if len(raw) != 1 || !bytes.Equal(raw[0].Rdata, []byte{0xde, 0xad, 0xbe, 0xef}) {
+			t.Fatalf("unexpected generic rdata: %v", raw)
+		}
+	})
+}
+
+func TestParseError(t *testing.T) {
+	soa := "$ORIGIN example.\n$TTL 1h\n@ SOA ns hm 1 2 3 4 5\n"
+	cases := []struct {
+		zone     string
+		expected string
+	}{
+		{"$TTL 1h\n@ IN A 192.0.2.1\n", "line 2"},
+		{"$ORIGIN example.\n$TTL 1h\n@ SOA ns hm 1 2 3 4 5\n\nwww IN A 192.0.2\n", "line 5"},
+		{"$ORIGIN example.\n@ 1h SOA ns hm 1 2 3 4 5\nwww IN BIE x\n", "line 3"},
+		{"$ORIGIN example.\n@ 1h SOA ns hm 1 2 3 4 5\nwww.other. IN A 192.0.2.1\n", "line 3"},
+		{soa + "www A (\n192.0.2.1\n", "line 4: unbalanced parenthesis"},
+		{soa + "txt TXT \"open\n", "line 4: unterminated quote"},
+		{soa + "x TXT a\n)\n", "line 5: unbalanced parenthesis"},
+		{soa + "@ SOA (ns hm\n1 2 3\n4) \n", "line 4: SOA: needs"},
+		{soa + "$GENERATE 3-1 h-$ A 192.0.2.$\n", "line 4: bad range"},
+		{soa + "$GENERATE 0-100000 h-$ A 192.0.2.1\n", "line 4: $GENERATE makes too many records"},
+		{soa + "$INCLUDE testdata/broken.zone\n", "line 4: testdata/broken.zone:2: A: bad address"},
+		{soa + "raw TYPE1 \\# 4 c000\n", "line 4: A: length is 4"},
+		{soa + "ch CH TXT hello\n", "line 4: class CH is not supported"},
+		{soa + "hs 300 CLASS4 A 192.0.2.1\n", "line 4: class CLASS4 is not supported"},
+		// 名前は "." 区切りの文字列で持つので、label の中の "." は読めない。
+		{soa + "a\\.b A 192.0.2.1\n", "line 4: dot in label is not supported"},
+		{soa + "www CNAME a\\046b\n", "line 4: CNAME: dot in label is not supported"},
+	}
+	for _, c := range cases {
+		_, err := Parse(strings.NewReader(c.zone), "")
+		if err == nil || !strings.HasPrefix(err.Error(), c.expected) {
+			t.Fatalf("expected error %q, but got %v", c.expected, err)
+		}
+	}
+}
+
+func TestClass(t *testing.T) {
+	z, err := Parse(strings.NewReader("$ORIGIN example.\n@ 1h CLASS1 SOA ns hm 1 2 3 4 5\nwww IN 300 A 192.0.2.1\n"), "")
+	if err != nil {
+		t.Fatalf("CLASS1 is IN: %v", err)
+	}
+	if www := z.Records("www.example."); len(www) != 1 || www[0].TTL != 300 {
+		t.Fatalf("unexpected www: %v", www)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected string
+	}{
+		{"host-$", "host-10"},
+		{"${5}", "15"},
+		{"${0,4}", "0010"},
+		{"${0,2,x}", "0a"},
+		{"${0,0,X}", "A"},
+		{`\$`, "$"},
+	}
+	for _, c := range cases {
+		if got, err := substitute(c.s, 10); err != nil || got != c.expected {
+			t.Fatalf("expected: %v, but got %v (%v)", c.expected, got, err)
+		}
+	}
+}
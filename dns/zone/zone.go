@@ -0,0 +1,240 @@
+package zone
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/nna774/zorori/dns"
+)
+
+// Zone is records of a zone
+type Zone struct {
+	origin  string
+	records map[string][]dns.ResourceRecord
+	// names は空の非終端も含めて存在する名前。
+	names map[string]bool
+}
+
+// New is ctor of Zone
+func New(origin string) *Zone {
+	return &Zone{
+		origin:  dns.Normalize(origin),
+		records: map[string][]dns.ResourceRecord{},
+		names:   map[string]bool{},
+	}
+}
+
+// Origin returns the apex of the zone
+func (z *Zone) Origin() string {
+	return z.origin
+}
+
+// Add adds rr to the zone
+func (z *Zone) Add(rr dns.ResourceRecord) error {
+	if !dns.IsSubDomain(rr.Name, z.origin) {
+		return fmt.Errorf("%v is out of zone %v", rr.Name, z.origin)
+	}
+	name := dns.Normalize(rr.Name)
+	z.records[name] = append(z.records[name], rr)
+	for _, n := range ancestors(name, z.origin) {
+		z.names[n] = true
+	}
+	z.names[z.origin] = true
+	return nil
+}
+
+// Records returns records of name
+func (z *Zone) Records(name string) []dns.ResourceRecord {
+	return z.records[dns.Normalize(name)]
+}
+
+// SOA returns SOA of the apex
+func (z *Zone) SOA() (dns.ResourceRecord, bool) {
+	rrs := filter(z.records[z.origin], dns.SOA)
+	if len(rrs) == 0 {
+		return dns.ResourceRecord{}, false
+	}
+	return rrs[0], true
+}
+
+// ancestors returns names from just below origin down to name.
+func ancestors(name, origin string) []string {
+	labels := dns.SplitLabels(dns.Normalize(name))
+	depth := len(labels) - len(dns.SplitLabels(origin))
+	result := make([]string, 0, depth)
+	for i := depth - 1; i >= 0; i-- {
+		result = append(result, dns.Fqdn(strings.Join(labels[i:], ".")))
+	}
+	return result
+}
+
+func filter(rrs []dns.ResourceRecord, t dns.QueryType) []dns.ResourceRecord {
+	result := []dns.ResourceRecord{}
+	for _, rr := range rrs {
+		if rr.T == t {
+			result = append(result, rr)
+		}
+	}
+	return result
+}
+
+// maxChain limits CNAME and DNAME in a response.
+const maxChain = 16
+
+// Lookup answers name of t authoritatively, name must be in the zone
+func (z *Zone) Lookup(name string, t dns.QueryType) dns.Answer {
+	ans := dns.NewAnswer(dns.NewQuestion(name, t))
+	ans.Header.SetRA(false)
+	ans.Header.SetAA(true)
+	for i := 0; i < maxChain; i++ {
+		if !dns.IsSubDomain(name, z.origin) {
+			// ゾーンの外に出た CNAME の先は resolver に任せる。
+			return ans
+		}
+		next, done := z.step(&ans, name, t)
+		if done {
+			return ans
+		}
+		name = next
+	}
+	ans.Header.SetRCode(dns.ServFail)
+	return ans
+}
+
+// step answers name into ans, and returns the next name if it continues by CNAME.
+func (z *Zone) step(ans *dns.Answer, name string, t dns.QueryType) (string, bool) {
+	for _, a := range ancestors(name, z.origin) {
+		rrs := z.records[a]
+		if ns := filter(rrs, dns.NS); len(ns) > 0 {
+			z.refer(ans, ns)
+			return "", true
+		}
+		if dname := filter(rrs, dns.DNAME); len(dname) > 0 && !dns.Same(a, name) {
+			cname, ok := dns.SynthesizeCNAME(dname[0], name)
+			if !ok {
+				ans.Header.SetRCode(dns.ServFail)
+				return "", true
+			}
+			ans.Answers = append(ans.Answers, dname[0], cname)
+			target, _ := cname.CNAMETO()
+			return target, false
+		}
+	}
+
+	key := dns.Normalize(name)
+	if z.names[key] {
+		return z.answer(ans, name, z.records[key], t)
+	}
+	// see RFC 4592
+	encloser := z.closestEncloser(key)
+	// 空の非終端の wildcard も一致して NODATA になる。
+	if z.names["*."+encloser] {
+		wild := z.records["*."+encloser]
+		synthesized := make([]dns.ResourceRecord, len(wild))
+		for i, rr := range wild {
+			rr.Name = dns.Fqdn(name)
+			synthesized[i] = rr
+		}
+		return z.answer(ans, name, synthesized, t)
+	}
+	ans.Header.SetRCode(dns.NXDomain)
+	z.addSOA(ans)
+	return "", true
+}
+
+// answer picks records of t from rrs, or CNAME to chase.
+func (z *Zone) answer(ans *dns.Answer, name string, rrs []dns.ResourceRecord, t dns.QueryType) (string, bool) {
+	if matched := filter(rrs, t); len(matched) > 0 {
+		ans.Answers = append(ans.Answers, matched...)
+		return "", true
+	}
+	if cname := filter(rrs, dns.CNAME); len(cname) > 0 {
+		ans.Answers = append(ans.Answers, cname[0])
+		target, _ := cname[0].CNAMETO()
+		for _, rr := range ans.Answers[:len(ans.Answers)-1] {
+			if rr.T == dns.CNAME && dns.Same(rr.Name, target) {
+				// loop
+				return "", true
+			}
+		}
+		return target, false
+	}
+	// NODATA
+	z.addSOA(ans)
+	return "", true
+}
+
+// refer makes ans a referral to the delegated zone with glue.
+func (z *Zone) refer(ans *dns.Answer, ns []dns.ResourceRecord) {
+	if len(ans.Answers) == 0 {
+		ans.Header.SetAA(false)
+	}
+	ans.Authorities = append(ans.Authorities, ns...)
+	for _, rr := range ns {
+		host, err := rr.NSName()
+		if err != nil || !dns.IsSubDomain(host, z.origin) {
+			continue
+		}
+		for _, glue := range z.records[dns.Normalize(host)] {
+			if glue.T == dns.A || glue.T == dns.AAAA {
+				ans.Additionals = append(ans.Additionals, glue)
+			}
+		}
+	}
+}
+
+// addSOA puts SOA into authorities for negative caching. see RFC 2308 3
+func (z *Zone) addSOA(ans *dns.Answer) {
+	soa, ok := z.SOA()
+	if !ok {
+		return
+	}
+	if minimum := binary.BigEndian.Uint32(soa.Rdata[len(soa.Rdata)-4:]); minimum < soa.TTL {
+		soa.TTL = minimum
+	}
+	ans.Authorities = append(ans.Authorities, soa)
+}
+
+func (z *Zone) closestEncloser(name string) string {
+	as := ancestors(name, z.origin)
+	for i := len(as) - 1; i >= 0; i-- {
+		if z.names[as[i]] {
+			return as[i]
+		}
+	}
+	return z.origin
+}
+
+// Authority answers queries for zones
+type Authority struct {
+	zones []*Zone
+}
+
+// NewAuthority is ctor of Authority
+func NewAuthority(zones ...*Zone) *Authority {
+	return &Authority{zones: zones}
+}
+
+// Zone returns the closest zone containing name
+func (a *Authority) Zone(name string) (*Zone, bool) {
+	var found *Zone
+	for _, z := range a.zones {
+		if !dns.IsSubDomain(name, z.origin) {
+			continue
+		}
+		if found == nil || len(z.origin) > len(found.origin) {
+			found = z
+		}
+	}
+	return found, found != nil
+}
+
+// ServeDNS answers q from the zone, or REFUSED if it is not ours
+func (a *Authority) ServeDNS(q dns.Query) (dns.Answer, error) {
+	z, ok := a.Zone(q.Question.Name())
+	if !ok {
+		return dns.NewErrorAnswer(q, dns.Refused), nil
+	}
+	return z.Lookup(q.Question.Name(), q.Question.Type()), nil
+}
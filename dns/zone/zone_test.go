@@ -0,0 +1,93 @@
+package zone
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nna774/zorori/dns"
+)
+
+func load(t *testing.T) *Zone {
+	z, err := Load("testdata/example.com.zone", "")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	return z
+}
+
+func names(rrs []dns.ResourceRecord) []string {
+	result := make([]string, len(rrs))
+	for i, rr := range rrs {
+		result[i] = rr.Name + " " + rr.T.String()
+	}
+	return result
+}
+
+func TestLookup(t *testing.T) {
+	z := load(t)
+	cases := []struct {
+		name        string
+		t           dns.QueryType
+		rcode       int
+		aa          bool
+		answers     string
+		authorities string
+		additionals int
+	}{
+		{"example.com.", dns.A, dns.NoError, true, "example.com. A", "", 0},
+		{"Alias.example.com.", dns.A, dns.NoError, true, "alias.example.com. CNAME,www.example.com. CNAME,example.com. A", "", 0},
+		{"www.example.com.", dns.AAAA, dns.NoError, true, "www.example.com. CNAME", "example.com. SOA", 0},
+		{"nx.example.com.", dns.A, dns.NXDomain, true, "", "example.com. SOA", 0},
+		{"deep.ent.example.com.", dns.A, dns.NoError, true, "", "example.com. SOA", 0},
+		{"a.wild.example.com.", dns.AAAA, dns.NoError, true, "a.wild.example.com. AAAA", "", 0},
+		{"b.a.wild.example.com.", dns.A, dns.NoError, true, "b.a.wild.example.com. A", "", 0},
+		{"a.ewild.example.com.", dns.A, dns.NoError, true, "", "example.com. SOA", 0},
+		{"www.sub.example.com.", dns.A, dns.NoError, false, "", "sub.example.com. NS,sub.example.com. NS", 1},
+		{"sub.example.com.", dns.NS, dns.NoError, false, "", "sub.example.com. NS,sub.example.com. NS", 1},
+		{"loop1.example.com.", dns.A, dns.NoError, true, "loop1.example.com. CNAME,loop2.example.com. CNAME", "", 0},
+		{"out.example.com.", dns.A, dns.NoError, true, "out.example.com. CNAME", "", 0},
+		{"x.legacy.example.com.", dns.A, dns.NoError, true, "legacy.example.com. DNAME,x.legacy.example.com. CNAME", "", 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name+c.t.String(), func(t *testing.T) {
+			ans := z.Lookup(c.name, c.t)
+			if ans.Header.RCode() != c.rcode || ans.Header.AA() != c.aa {
+				t.Fatalf("unexpected header: %v", ans.Header)
+			}
+			if got := strings.Join(names(ans.Answers), ","); got != c.answers {
+				t.Fatalf("expected answers: %v, but got %v", c.answers, got)
+			}
+			if got := strings.Join(names(ans.Authorities), ","); got != c.authorities {
+				t.Fatalf("expected authorities: %v, but got %v", c.authorities, got)
+			}
+			if len(ans.Additionals) != c.additionals {
+				t.Fatalf("expected %v additionals, but got %v", c.additionals, ans.Additionals)
+			}
+		})
+	}
+}
+
+func TestNegativeTTL(t *testing.T) {
+	z := load(t)
+	ans := z.Lookup("nx.example.com.", dns.A)
+	if ttl := ans.Authorities[0].TTL; ttl != 300 {
+		t.Fatalf("SOA TTL should be minimum: %v", ttl)
+	}
+}
+
+func TestAuthority(t *testing.T) {
+	sub := New("sub.example.com.")
+	sub.Add(dns.NewResourceRecord("www.sub.example.com.", dns.A, dns.IN, 60, []byte{192, 0, 2, 200}))
+	a := NewAuthority(load(t), sub)
+
+	q := dns.NewQuery("www.sub.example.com.", dns.A)
+	ans, _ := a.ServeDNS(q)
+	if !ans.Header.AA() || len(ans.Answers) != 1 {
+		t.Fatalf("should be answered by the closest zone: %v", ans)
+	}
+	q = dns.NewQuery("www.example.net.", dns.A)
+	ans, _ = a.ServeDNS(q)
+	if ans.Header.RCode() != dns.Refused {
+		t.Fatalf("expected: REFUSED, but got %v", ans.Header.RCode())
+	}
+}
@@ -0,0 +1,213 @@
+package resolver
+
+import (
+	"net"
+	"sort"
+)
+
+// SortByRFC6724 sorts addrs by destination address selection of RFC 6724
+func SortByRFC6724(addrs []net.IP) {
+	if len(addrs) < 2 {
+		return
+	}
+	srcs := make([]net.IP, len(addrs))
+	for i, dst := range addrs {
+		srcs[i] = sourceAddr(dst)
+	}
+	sortByRFC6724WithSrcs(addrs, srcs)
+}
+
+// sourceAddr returns the source addr kernel would use for dst, or nil if unreachable.
+func sourceAddr(dst net.IP) net.IP {
+	// UDP の connect はパケットを送らない。
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: dst, Port: 9})
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
+
+type byRFC6724 struct {
+	addrs []net.IP
+	srcs  []net.IP
+	attrs []ipAttr
+	sattr []ipAttr
+}
+
+func sortByRFC6724WithSrcs(addrs, srcs []net.IP) {
+	s := byRFC6724{
+		addrs: addrs,
+		srcs:  srcs,
+		attrs: make([]ipAttr, len(addrs)),
+		sattr: make([]ipAttr, len(addrs)),
+	}
+	for i := range addrs {
+		s.attrs[i] = attrOf(addrs[i])
+		if srcs[i] != nil {
+			s.sattr[i] = attrOf(srcs[i])
+		}
+	}
+	sort.Stable(&s)
+}
+
+func (s *byRFC6724) Len() int { return len(s.addrs) }
+
+func (s *byRFC6724) Swap(i, j int) {
+	s.addrs[i], s.addrs[j] = s.addrs[j], s.addrs[i]
+	s.srcs[i], s.srcs[j] = s.srcs[j], s.srcs[i]
+	s.attrs[i], s.attrs[j] = s.attrs[j], s.attrs[i]
+	s.sattr[i], s.sattr[j] = s.sattr[j], s.sattr[i]
+}
+
+// Less reports whether addrs[i] is preferred to addrs[j]. see RFC 6724 section 6.
+func (s *byRFC6724) Less(i, j int) bool {
+	da, db := s.attrs[i], s.attrs[j]
+	sa, sb := s.sattr[i], s.sattr[j]
+
+	// Rule 1: Avoid unusable destinations.
+	if (s.srcs[i] == nil) != (s.srcs[j] == nil) {
+		return s.srcs[j] == nil
+	}
+	if s.srcs[i] == nil {
+		return false
+	}
+
+	// Rule 2: Prefer matching scope.
+	if (da.scope == sa.scope) != (db.scope == sb.scope) {
+		return da.scope == sa.scope
+	}
+
+	// Rule 3: Avoid deprecated addresses.
+	// Rule 4: Prefer home addresses.
+	// 送信元アドレスの状態 (deprecated か、Mobile IPv6 の home address か) は
+	// UDP で connect して得たアドレスからはわからないので飛ばす。
+
+	// Rule 5: Prefer matching label.
+	if (da.label == sa.label) != (db.label == sb.label) {
+		return da.label == sa.label
+	}
+
+	// Rule 6: Prefer higher precedence.
+	if da.precedence != db.precedence {
+		return da.precedence > db.precedence
+	}
+
+	// Rule 7: Prefer native transport.
+	// 経路がカプセル化されているかどうかもわからないので飛ばす。
+
+	// Rule 8: Prefer smaller scope.
+	if da.scope != db.scope {
+		return da.scope < db.scope
+	}
+
+	// Rule 9: Use longest matching prefix.
+	// IPv4 に適用すると DNS のラウンドロビンが壊れるので IPv6 同士のときだけ。
+	if s.addrs[i].To4() == nil && s.addrs[j].To4() == nil {
+		ca := commonPrefixLen(s.srcs[i], s.addrs[i])
+		cb := commonPrefixLen(s.srcs[j], s.addrs[j])
+		if ca != cb {
+			return ca > cb
+		}
+	}
+
+	// Rule 10: Otherwise, leave the order unchanged.
+	return false
+}
+
+type ipAttr struct {
+	scope      int
+	precedence int
+	label      int
+}
+
+type policy struct {
+	prefix     *net.IPNet
+	precedence int
+	label      int
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// RFC 6724 section 2.1 のデフォルトポリシー。長いプレフィックスから並べる。
+var policyTable = []policy{
+	{mustCIDR("::1/128"), 50, 0},
+	{mustCIDR("::ffff:0:0/96"), 35, 4},
+	{mustCIDR("::/96"), 1, 3},
+	{mustCIDR("2001::/32"), 5, 5},
+	{mustCIDR("2002::/16"), 30, 2},
+	{mustCIDR("3ffe::/16"), 1, 12},
+	{mustCIDR("fec0::/10"), 1, 11},
+	{mustCIDR("fc00::/7"), 3, 13},
+	{mustCIDR("::/0"), 40, 1},
+}
+
+const (
+	scopeLinkLocal = 0x2
+	scopeSiteLocal = 0x5
+	scopeGlobal    = 0xe
+)
+
+func attrOf(ip net.IP) ipAttr {
+	a := ipAttr{scope: scopeOf(ip)}
+	ip16 := ip.To16()
+	for _, p := range policyTable {
+		if p.prefix.Contains(ip16) {
+			a.precedence = p.precedence
+			a.label = p.label
+			break
+		}
+	}
+	return a
+}
+
+func scopeOf(ip net.IP) int {
+	if ip4 := ip.To4(); ip4 != nil {
+		// RFC 6724 section 3.2
+		if ip4.IsLoopback() || ip4.IsLinkLocalUnicast() {
+			return scopeLinkLocal
+		}
+		return scopeGlobal
+	}
+	if ip.IsMulticast() {
+		return int(ip[1] & 0xf)
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return scopeLinkLocal
+	}
+	if ip[0] == 0xfe && ip[1]&0xc0 == 0xc0 {
+		return scopeSiteLocal
+	}
+	return scopeGlobal
+}
+
+// commonPrefixLen returns common prefix bits length up to 64.
+func commonPrefixLen(a, b net.IP) int {
+	a, b = a.To16(), b.To16()
+	if a == nil || b == nil || (a.To4() == nil) != (b.To4() == nil) {
+		return 0
+	}
+	n := 0
+	for i := 0; i < 8; i++ {
+		if a[i] == b[i] {
+			n += 8
+			continue
+		}
+		x := a[i] ^ b[i]
+		for x&0x80 == 0 {
+			n++
+			x <<= 1
+		}
+		break
+	}
+	return n
+}
@@ -0,0 +1,86 @@
+package resolver
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSortByRFC6724(t *testing.T) {
+	cases := []struct {
+		name     string
+		addrs    []net.IP
+		srcs     []net.IP
+		expected []net.IP
+	}{
+		{
+			name: "prefer v6 when both usable",
+			addrs: []net.IP{
+				net.ParseIP("192.0.2.1"),
+				net.ParseIP("2001:db8::1"),
+			},
+			srcs: []net.IP{
+				net.ParseIP("198.51.100.1"),
+				net.ParseIP("2001:db8::2"),
+			},
+			expected: []net.IP{
+				net.ParseIP("2001:db8::1"),
+				net.ParseIP("192.0.2.1"),
+			},
+		},
+		{
+			name: "avoid unusable",
+			addrs: []net.IP{
+				net.ParseIP("2001:db8::1"),
+				net.ParseIP("192.0.2.1"),
+			},
+			srcs: []net.IP{
+				nil,
+				net.ParseIP("198.51.100.1"),
+			},
+			expected: []net.IP{
+				net.ParseIP("192.0.2.1"),
+				net.ParseIP("2001:db8::1"),
+			},
+		},
+		{
+			name: "longest matching prefix",
+			addrs: []net.IP{
+				net.ParseIP("2001:db8:1::1"),
+				net.ParseIP("2001:db8:2::1"),
+			},
+			srcs: []net.IP{
+				net.ParseIP("2001:db8:3::2"),
+				net.ParseIP("2001:db8:2::2"),
+			},
+			expected: []net.IP{
+				net.ParseIP("2001:db8:2::1"),
+				net.ParseIP("2001:db8:1::1"),
+			},
+		},
+		{
+			name: "keep order of v4",
+			addrs: []net.IP{
+				net.ParseIP("192.0.2.2"),
+				net.ParseIP("192.0.2.1"),
+			},
+			srcs: []net.IP{
+				net.ParseIP("192.0.2.100"),
+				net.ParseIP("192.0.2.100"),
+			},
+			expected: []net.IP{
+				net.ParseIP("192.0.2.2"),
+				net.ParseIP("192.0.2.1"),
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sortByRFC6724WithSrcs(c.addrs, c.srcs)
+			for i := range c.expected {
+				if !c.expected[i].Equal(c.addrs[i]) {
+					t.Fatalf("expected: %v, but got %v", c.expected, c.addrs)
+				}
+			}
+		})
+	}
+}
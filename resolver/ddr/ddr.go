@@ -0,0 +1,206 @@
+package ddr
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/doh"
+	"github.com/nna774/zorori/resolver/dot"
+	"github.com/nna774/zorori/resolver/udp"
+	"github.com/pkg/errors"
+)
+
+// see RFC 9462
+const resolverName = "_dns.resolver.arpa"
+
+const timeout = 5 * time.Second
+
+// Protocol is encrypted DNS protocol
+type Protocol string
+
+const (
+	// DoH is DNS over HTTPS
+	DoH Protocol = "doh"
+	// DoT is DNS over TLS
+	DoT Protocol = "dot"
+	// DoQ is DNS over QUIC
+	DoQ Protocol = "doq"
+)
+
+// Designated is encrypted resolver advertised by DDR
+type Designated struct {
+	Protocol Protocol
+	Priority uint16
+	Target   string
+	Port     uint16
+	Addrs    []net.IP
+	DoHPath  string
+}
+
+// ErrNoDesignated is returned when no usable designated resolver found
+var ErrNoDesignated = errors.New("no usable designated resolver")
+
+// Discover asks the Do53 resolver at ip for designated resolvers, and returns verified ones in priority order
+func Discover(ip net.IP) ([]Designated, error) {
+	stub := udp.NewUDPStubResolver(ip)
+	res, err := stub.SVCBResolve(resolverName)
+	if err != nil {
+		return nil, errors.Wrap(err, "DDR query failed")
+	}
+	verified := []Designated{}
+	for _, d := range endpoints(res) {
+		if len(d.Addrs) == 0 {
+			d.Addrs, _ = resolver.LookupIP(stub, d.Target)
+		}
+		if err := verify(&d, ip, nil); err != nil {
+			continue
+		}
+		verified = append(verified, d)
+	}
+	return verified, nil
+}
+
+// NewResolver returns encrypted resolver designated by the Do53 resolver at ip
+func NewResolver(ip net.IP) (resolver.Resolver, error) {
+	ds, err := Discover(ip)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range ds {
+		if r, err := d.Resolver(); err == nil {
+			return r, nil
+		}
+	}
+	return nil, ErrNoDesignated
+}
+
+// Resolver returns resolver for d
+func (d *Designated) Resolver() (resolver.Resolver, error) {
+	switch d.Protocol {
+	case DoH:
+		// 検証したアドレスに繋ぐ。
+		return doh.NewDoHResolver(d.URL(), doh.WithBootstrapIPs(d.Addrs...)), nil
+	case DoT:
+		if len(d.Addrs) == 0 {
+			return nil, ErrNoDesignated
+		}
+		return dot.NewDoTResolver(net.JoinHostPort(d.Addrs[0].String(), strconv.Itoa(int(d.Port))), host(d.Target)), nil
+	default:
+		// DoQ はまだ無い。
+		return nil, errors.Errorf("%v is not supported", d.Protocol)
+	}
+}
+
+// URL returns DoH URL without the template part
+func (d *Designated) URL() string {
+	h := host(d.Target)
+	if d.Port != 443 {
+		h = net.JoinHostPort(h, strconv.Itoa(int(d.Port)))
+	}
+	u := url.URL{
+		Scheme: "https",
+		Host:   h,
+		Path:   strings.Replace(d.DoHPath, "{?dns}", "", 1),
+	}
+	return u.String()
+}
+
+func host(name string) string {
+	return strings.TrimSuffix(name, ".")
+}
+
+// endpoints expands SVCB records to each protocol. see RFC 9461
+func endpoints(res []dns.SVCBResult) []Designated {
+	ds := []Designated{}
+	for _, r := range res {
+		alpn, _ := r.Params.ALPN()
+		port, hasPort := r.Params.Port()
+		v4, _ := r.Params.IPv4Hint()
+		v6, _ := r.Params.IPv6Hint()
+		path, hasPath := r.Params.DoHPath()
+		seen := map[Protocol]bool{}
+		for _, id := range alpn {
+			d := Designated{
+				Priority: r.Priority,
+				Target:   r.Target,
+				Port:     port,
+				Addrs:    append(append([]net.IP{}, v4...), v6...),
+			}
+			switch id {
+			case "h2", "h3":
+				if !hasPath {
+					continue
+				}
+				d.Protocol = DoH
+				d.DoHPath = path
+				if !hasPort {
+					d.Port = 443
+				}
+			case "dot":
+				d.Protocol = DoT
+				if !hasPort {
+					d.Port = 853
+				}
+			case "doq":
+				d.Protocol = DoQ
+				if !hasPort {
+					d.Port = 853
+				}
+			default:
+				continue
+			}
+			if seen[d.Protocol] {
+				continue
+			}
+			seen[d.Protocol] = true
+			ds = append(ds, d)
+		}
+	}
+	sort.SliceStable(ds, func(i, j int) bool {
+		return ds[i].Priority < ds[j].Priority
+	})
+	return ds
+}
+
+// verify checks that certificate of d is valid for the target and covers original ip. see RFC 9462 section 4.2
+func verify(d *Designated, original net.IP, config *tls.Config) error {
+	if d.Protocol == DoQ {
+		return errors.New("DoQ is not supported")
+	}
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	config.ServerName = host(d.Target)
+	if d.Protocol == DoT {
+		config.NextProtos = []string{"dot"}
+	}
+	var lastErr error = ErrNoDesignated
+	for _, addr := range d.Addrs {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", net.JoinHostPort(addr.String(), strconv.Itoa(int(d.Port))), config)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		certs := conn.ConnectionState().PeerCertificates
+		conn.Close()
+		if len(certs) == 0 {
+			lastErr = errors.New("no certificate")
+			continue
+		}
+		if err := certs[0].VerifyHostname(original.String()); err != nil {
+			lastErr = errors.Wrap(err, "certificate does not cover the original resolver")
+			continue
+		}
+		d.Addrs = []net.IP{addr}
+		return nil
+	}
+	return lastErr
+}
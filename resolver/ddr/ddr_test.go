@@ -0,0 +1,52 @@
+package ddr
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nna774/zorori/dns"
+)
+
+func TestEndpoints(t *testing.T) {
+	doh, _ := dns.ParseSVCB(`1 dns.example.net. alpn=h2,h3 dohpath=/dns-query{?dns} ipv4hint=192.0.2.1`)
+	dot, _ := dns.ParseSVCB(`2 dns.example.net. alpn=dot port=8853`)
+	ds := endpoints([]dns.SVCBResult{dot, doh})
+	if len(ds) != 2 {
+		t.Fatalf("expected 2 endpoints, but got %v", ds)
+	}
+	if ds[0].Protocol != DoH || ds[0].Port != 443 || ds[0].URL() != "https://dns.example.net/dns-query" {
+		t.Fatalf("unexpected DoH endpoint: %v", ds[0])
+	}
+	if len(ds[0].Addrs) != 1 || !ds[0].Addrs[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("unexpected hints: %v", ds[0].Addrs)
+	}
+	if ds[1].Protocol != DoT || ds[1].Port != 8853 {
+		t.Fatalf("unexpected DoT endpoint: %v", ds[1])
+	}
+}
+
+func TestVerify(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	config := &tls.Config{RootCAs: pool}
+
+	// httptest の証明書は example.com と 127.0.0.1 を含む。
+	d := Designated{Protocol: DoH, Target: "example.com.", Port: uint16(addr.Port), Addrs: []net.IP{addr.IP}}
+	if err := verify(&d, net.ParseIP("127.0.0.1"), config); err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if err := verify(&d, net.ParseIP("192.0.2.53"), config); err == nil {
+		t.Fatalf("certificate without original ip should not be verified")
+	}
+	d.Target = "other.example."
+	if err := verify(&d, net.ParseIP("127.0.0.1"), config); err == nil {
+		t.Fatalf("certificate without target name should not be verified")
+	}
+}
@@ -0,0 +1,457 @@
+package dialer
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultResolutionDelay is time to wait AAAA after A is answered. see RFC 8305
+	DefaultResolutionDelay = 50 * time.Millisecond
+	// DefaultConnectionAttemptDelay is time to wait before the next connection attempt. see RFC 8305
+	DefaultConnectionAttemptDelay = 250 * time.Millisecond
+)
+
+var errNoAddress = errors.New("no address")
+
+// Dialer dials with addrs resolved by resolver, racing them by Happy Eyeballs v2
+type Dialer struct {
+	resolver        resolver.Resolver
+	dialer          *net.Dialer
+	scheme          string
+	resolutionDelay time.Duration
+	attemptDelay    time.Duration
+}
+
+// Option is option of Dialer
+type Option func(*Dialer)
+
+// WithNetDialer dials each attempt by d
+func WithNetDialer(d *net.Dialer) Option {
+	return func(dd *Dialer) {
+		dd.dialer = d
+	}
+}
+
+// WithScheme looks up SVCB of _port._scheme instead of HTTPS, or nothing if scheme is empty
+func WithScheme(scheme string) Option {
+	return func(d *Dialer) {
+		d.scheme = scheme
+	}
+}
+
+// WithResolutionDelay sets resolution delay
+func WithResolutionDelay(delay time.Duration) Option {
+	return func(d *Dialer) {
+		d.resolutionDelay = delay
+	}
+}
+
+// WithConnectionAttemptDelay sets connection attempt delay
+func WithConnectionAttemptDelay(delay time.Duration) Option {
+	return func(d *Dialer) {
+		d.attemptDelay = delay
+	}
+}
+
+// New is ctor of Dialer
+func New(r resolver.Resolver, opts ...Option) *Dialer {
+	d := &Dialer{
+		resolver:        r,
+		dialer:          &net.Dialer{},
+		scheme:          "https",
+		resolutionDelay: DefaultResolutionDelay,
+		attemptDelay:    DefaultConnectionAttemptDelay,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Dial connects to address
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to address, it can be used as http.Transport.DialContext
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	p, err := net.LookupPort(network, port)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := d.lookup(ctx, network, host, uint16(p))
+	if err != nil {
+		return nil, err
+	}
+	return d.race(ctx, network, addrs)
+}
+
+type lookupResult struct {
+	ips []net.IP
+	err error
+}
+
+// lookup resolves HTTPS or SVCB, AAAA and A concurrently, and returns addrs to try in order.
+func (d *Dialer) lookup(ctx context.Context, network, host string, port uint16) ([]string, error) {
+	v6 := make(chan lookupResult, 1)
+	v4 := make(chan lookupResult, 1)
+	svcb := make(chan []service, 1)
+	fam := family(network)
+	if fam == "4" {
+		// 使えない family は引かない。
+		v6 <- lookupResult{}
+	} else {
+		go func() {
+			res, err := d.resolver.AAAAResolve(host)
+			v6 <- lookupResult{ips: res.IPs(), err: err}
+		}()
+	}
+	if fam == "6" {
+		v4 <- lookupResult{}
+	} else {
+		go func() {
+			res, err := d.resolver.AResolve(host)
+			v4 <- lookupResult{ips: res.IPs(), err: err}
+		}()
+	}
+	if network == "udp" || network == "udp4" || network == "udp6" || d.scheme == "" {
+		svcb <- nil
+	} else {
+		go func() {
+			res, _ := d.lookupSVCB(host, port)
+			svcb <- d.resolveServices(res, host, fam)
+		}()
+	}
+
+	var r6, r4 *lookupResult
+	var services []service
+	svcbDone := false
+	var delay <-chan time.Time
+	expired := false
+	for {
+		addrsReady := (r6 != nil && r4 != nil) ||
+			(r6 != nil && len(r6.ips) > 0) ||
+			(r4 != nil && len(r4.ips) > 0 && expired)
+		if addrsReady && (svcbDone || expired) {
+			break
+		}
+		select {
+		case r := <-v6:
+			r6 = &r
+		case r := <-v4:
+			r4 = &r
+		case services = <-svcb:
+			svcbDone = true
+		case <-delay:
+			expired = true
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if delay == nil && (r6 != nil || r4 != nil) {
+			delay = time.After(d.resolutionDelay)
+		}
+	}
+	// 遅れて届いた A も候補に入れる。
+	if r4 == nil {
+		select {
+		case r := <-v4:
+			r4 = &r
+		default:
+		}
+	}
+
+	ips := []net.IP{}
+	var lastErr error
+	for _, r := range []*lookupResult{r6, r4} {
+		if r == nil {
+			continue
+		}
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		ips = append(ips, r.ips...)
+	}
+	addrs := []string{}
+	for _, addr := range append(serviceAddrs(services, port, ips), joinPort(interleave(ips), port)...) {
+		if !contains(addrs, addr) && matchFamily(addr, fam) {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		if lastErr != nil {
+			return nil, errors.Wrap(lastErr, "lookup failed")
+		}
+		return nil, errNoAddress
+	}
+	return addrs, nil
+}
+
+// family returns "4" or "6" if network is restricted to the family, or "".
+func family(network string) string {
+	switch network {
+	case "tcp4", "udp4":
+		return "4"
+	case "tcp6", "udp6":
+		return "6"
+	}
+	return ""
+}
+
+func matchFamily(addr, fam string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	switch fam {
+	case "4":
+		return ip.To4() != nil
+	case "6":
+		return ip.To4() == nil
+	}
+	return true
+}
+
+func (d *Dialer) lookupSVCB(host string, port uint16) ([]dns.SVCBResult, error) {
+	if d.scheme == "https" {
+		if port == 443 {
+			return d.resolver.HTTPSResolve(host)
+		}
+		return d.resolver.HTTPSResolve("_" + strconv.Itoa(int(port)) + "._https." + host)
+	}
+	// see RFC 9460 2.3
+	return d.resolver.SVCBResolve("_" + strconv.Itoa(int(port)) + "._" + d.scheme + "." + host)
+}
+
+// service is a usable SVCB record with addrs of its target.
+type service struct {
+	dns.SVCBResult
+	// sameHost なら target は host なので、host の addr を使う。
+	sameHost bool
+	ips      []net.IP
+}
+
+// resolveServices resolves targets of usable services in order, only the family fam.
+func (d *Dialer) resolveServices(services []dns.SVCBResult, host, fam string) []service {
+	result := []service{}
+	for _, s := range services {
+		if !usable(s) {
+			continue
+		}
+		svc := service{SVCBResult: s, sameHost: dns.Same(s.Target, host)}
+		if !svc.sameHost {
+			svc.ips = d.lookupFamily(s.Target, fam)
+		}
+		result = append(result, svc)
+	}
+	return result
+}
+
+func (d *Dialer) lookupFamily(name, fam string) []net.IP {
+	switch fam {
+	case "4":
+		res, _ := d.resolver.AResolve(name)
+		return res.IPs()
+	case "6":
+		res, _ := d.resolver.AAAAResolve(name)
+		return res.IPs()
+	}
+	ips, _ := resolver.LookupIP(d.resolver, name)
+	return ips
+}
+
+// serviceAddrs returns addrs of the most preferred service which has any addr.
+func serviceAddrs(services []service, port uint16, hostIPs []net.IP) []string {
+	for _, s := range services {
+		p, ok := s.Params.Port()
+		if !ok {
+			p = port
+		}
+		ips := append([]net.IP{}, s.ips...)
+		if s.sameHost {
+			ips = append(ips, hostIPs...)
+		}
+		// 解決できなくても hint があればそれを使う。
+		if hints, ok := s.Params.IPv6Hint(); ok {
+			ips = appendNew(ips, hints...)
+		}
+		if hints, ok := s.Params.IPv4Hint(); ok {
+			ips = appendNew(ips, hints...)
+		}
+		if len(ips) == 0 {
+			continue
+		}
+		return joinPort(interleave(ips), p)
+	}
+	return nil
+}
+
+// usable decides we understand all mandatory keys of s, and it has a protocol over TCP. see RFC 9460 7.1.2, 8
+func usable(s dns.SVCBResult) bool {
+	keys, _ := s.Params.Mandatory()
+	for _, k := range keys {
+		switch k {
+		case dns.KeyALPN, dns.KeyNoDefaultALPN, dns.KeyPort, dns.KeyIPv4Hint, dns.KeyIPv6Hint:
+		default:
+			return false
+		}
+	}
+	if !s.Params.NoDefaultALPN() {
+		// 既定の ALPN (https なら http/1.1) は TCP で話せる。
+		return true
+	}
+	ids, _ := s.Params.ALPN()
+	for _, id := range ids {
+		if !overUDP(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// overUDP reports whether protocol id runs only over QUIC.
+func overUDP(id string) bool {
+	return id == "h3" || strings.HasPrefix(id, "h3-") || id == "doq"
+}
+
+// interleave sorts ips by RFC 6724, and alternates address families. see RFC 8305 4
+func interleave(ips []net.IP) []net.IP {
+	sorted := make([]net.IP, len(ips))
+	copy(sorted, ips)
+	resolver.SortByRFC6724(sorted)
+	if len(sorted) == 0 {
+		return sorted
+	}
+	first, second := []net.IP{}, []net.IP{}
+	for _, ip := range sorted {
+		if (ip.To4() == nil) == (sorted[0].To4() == nil) {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+	result := make([]net.IP, 0, len(sorted))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			result = append(result, first[i])
+		}
+		if i < len(second) {
+			result = append(result, second[i])
+		}
+	}
+	return result
+}
+
+func joinPort(ips []net.IP, port uint16) []string {
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+	}
+	return addrs
+}
+
+func appendNew(ips []net.IP, news ...net.IP) []net.IP {
+	for _, n := range news {
+		found := false
+		for _, ip := range ips {
+			if ip.Equal(n) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ips = append(ips, n)
+		}
+	}
+	return ips
+}
+
+func contains(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// race starts connection attempts one by one every attempt delay, and returns the first established. see RFC 8305 5
+func (d *Dialer) race(ctx context.Context, network string, addrs []string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	next := 0
+	pending := 0
+	start := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			conn, err := d.dialer.DialContext(ctx, network, addr)
+			results <- result{conn: conn, err: err}
+		}()
+	}
+	// 負けた接続は閉じる。
+	drain := func(n int) {
+		for i := 0; i < n; i++ {
+			if r := <-results; r.conn != nil {
+				r.conn.Close()
+			}
+		}
+	}
+
+	start()
+	var lastErr error
+	for pending > 0 {
+		var timer *time.Timer
+		var tick <-chan time.Time
+		if next < len(addrs) {
+			timer = time.NewTimer(d.attemptDelay)
+			tick = timer.C
+		}
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				go drain(pending)
+				if timer != nil {
+					timer.Stop()
+				}
+				return r.conn, nil
+			}
+			lastErr = r.err
+			if next < len(addrs) {
+				start()
+			}
+		case <-tick:
+			start()
+		case <-ctx.Done():
+			go drain(pending)
+			return nil, ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,261 @@
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver/resolvertest"
+)
+
+func listen(t *testing.T) (net.Listener, uint16) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	return l, uint16(l.Addr().(*net.TCPAddr).Port)
+}
+
+// closedPort returns port nobody listens.
+func closedPort(t *testing.T) uint16 {
+	l, port := listen(t)
+	l.Close()
+	return port
+}
+
+func TestDialHTTPS(t *testing.T) {
+	l, port := listen(t)
+	defer l.Close()
+	s := dns.SVCBResult{Priority: 1, Target: ".", Params: dns.NewSvcParams()}
+	s.Params.SetPort(port)
+	s.Params.SetIPv4Hint(net.ParseIP("127.0.0.1"))
+	rr, err := s.ResourceRecord(dns.HTTPS, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr.Name = "svc.example."
+	d := New(resolvertest.New(resolvertest.Zone(map[string][]dns.ResourceRecord{"svc.example.": {rr}})))
+
+	conn, err := d.Dial("tcp", "svc.example:443")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got != l.Addr().String() {
+		t.Fatalf("expected: %v, but got %v", l.Addr(), got)
+	}
+}
+
+func TestDialAlias(t *testing.T) {
+	l, port := listen(t)
+	defer l.Close()
+	alias := dns.SVCBResult{Priority: 0, Target: "cdn.example.", Params: dns.NewSvcParams()}
+	rr, err := alias.ResourceRecord(dns.HTTPS, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr.Name = fmt.Sprintf("_%d._https.svc.example.", port)
+	d := New(resolvertest.New(resolvertest.Zone(map[string][]dns.ResourceRecord{
+		rr.Name: {rr},
+		// alias の先に SVCB は無く、元の名前のアドレスには繋がらない。
+		"cdn.example.": {dns.NewResourceRecord("cdn.example.", dns.A, dns.IN, 300, []byte{127, 0, 0, 1})},
+		"svc.example.": {dns.NewResourceRecord("svc.example.", dns.A, dns.IN, 300, []byte{192, 0, 2, 1})},
+	})))
+
+	conn, err := d.Dial("tcp", net.JoinHostPort("svc.example", strconv.Itoa(int(port))))
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got != l.Addr().String() {
+		t.Fatalf("expected: %v, but got %v", l.Addr(), got)
+	}
+}
+
+func TestLookupFamily(t *testing.T) {
+	d := New(resolvertest.New(resolvertest.Zone(map[string][]dns.ResourceRecord{"dual.example.": {
+		dns.NewResourceRecord("dual.example.", dns.A, dns.IN, 300, []byte{192, 0, 2, 1}),
+		dns.NewResourceRecord("dual.example.", dns.AAAA, dns.IN, 300, net.ParseIP("2001:db8::1")),
+	}})), WithScheme(""))
+	cases := []struct {
+		network  string
+		expected string
+	}{
+		{"tcp", "192.0.2.1:80,[2001:db8::1]:80"},
+		{"tcp4", "192.0.2.1:80"},
+		{"tcp6", "[2001:db8::1]:80"},
+		{"udp4", "192.0.2.1:80"},
+	}
+	for _, c := range cases {
+		t.Run(c.network, func(t *testing.T) {
+			addrs, err := d.lookup(context.Background(), c.network, "dual.example.", 80)
+			if err != nil {
+				t.Fatalf("err should be nil: %v", err)
+			}
+			// 順序は環境の経路で変わるので並べ替えて比べる。
+			sort.Strings(addrs)
+			if got := strings.Join(addrs, ","); got != c.expected {
+				t.Fatalf("expected: %v, but got %v", c.expected, got)
+			}
+		})
+	}
+}
+
+// serviceZone has HTTPS of svc.example. whose target is dual.example.
+func serviceZone(t *testing.T) map[string][]dns.ResourceRecord {
+	s := dns.SVCBResult{Priority: 1, Target: "dual.example.", Params: dns.NewSvcParams()}
+	rr, err := s.ResourceRecord(dns.HTTPS, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr.Name = "svc.example."
+	return map[string][]dns.ResourceRecord{
+		"svc.example.": {rr, dns.NewResourceRecord("svc.example.", dns.A, dns.IN, 300, []byte{198, 51, 100, 1})},
+		"dual.example.": {
+			dns.NewResourceRecord("dual.example.", dns.A, dns.IN, 300, []byte{192, 0, 2, 1}),
+			dns.NewResourceRecord("dual.example.", dns.AAAA, dns.IN, 300, net.ParseIP("2001:db8::1")),
+		},
+	}
+}
+
+func TestLookupServiceFamily(t *testing.T) {
+	zone := resolvertest.Zone(serviceZone(t))
+	asked := make(chan dns.QueryType, 10)
+	d := New(resolvertest.New(func(name string, qt dns.QueryType) (dns.Answer, error) {
+		if dns.Same(name, "dual.example.") {
+			asked <- qt
+		}
+		return zone(name, qt)
+	}))
+	addrs, err := d.lookup(context.Background(), "tcp4", "svc.example.", 443)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if addrs[0] != "192.0.2.1:443" {
+		t.Fatalf("target addr should be first: %v", addrs)
+	}
+	close(asked)
+	for qt := range asked {
+		if qt != dns.A {
+			t.Fatalf("target should be resolved only in the family, but asked %v", qt)
+		}
+	}
+}
+
+func TestLookupSlowServiceTarget(t *testing.T) {
+	zone := resolvertest.Zone(serviceZone(t))
+	block := make(chan struct{})
+	defer close(block)
+	d := New(resolvertest.New(func(name string, qt dns.QueryType) (dns.Answer, error) {
+		if dns.Same(name, "dual.example.") {
+			// target の解決が終わらなくても待たない。
+			<-block
+		}
+		return zone(name, qt)
+	}), WithResolutionDelay(10*time.Millisecond))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		addrs, err := d.lookup(context.Background(), "tcp4", "svc.example.", 443)
+		if err != nil || len(addrs) != 1 || addrs[0] != "198.51.100.1:443" {
+			t.Errorf("host addrs should be used: %v, %v", addrs, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lookup should not wait for the target")
+	}
+}
+
+func TestUsable(t *testing.T) {
+	cases := []struct {
+		name      string
+		alpn      []string
+		noDefault bool
+		expected  bool
+	}{
+		{"default", nil, false, true},
+		{"h3 with default", []string{"h3"}, false, true},
+		{"h3 only", []string{"h3"}, true, false},
+		{"h3 draft only", []string{"h3-29", "doq"}, true, false},
+		{"h2 and h3", []string{"h3", "h2"}, true, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := dns.SVCBResult{Priority: 1, Target: ".", Params: dns.NewSvcParams()}
+			if c.alpn != nil {
+				s.Params.SetALPN(c.alpn...)
+			}
+			if c.noDefault {
+				s.Params.SetNoDefaultALPN()
+			}
+			if got := usable(s); got != c.expected {
+				t.Fatalf("expected: %v, but got %v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestDialFallback(t *testing.T) {
+	l, port := listen(t)
+	defer l.Close()
+	d := New(resolvertest.New(resolvertest.Zone(map[string][]dns.ResourceRecord{})))
+	closed := net.JoinHostPort("127.0.0.1", strconv.Itoa(int(closedPort(t))))
+
+	conn, err := d.race(context.Background(), "tcp", []string{closed, l.Addr().String()})
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().(*net.TCPAddr).Port; got != int(port) {
+		t.Fatalf("expected: %v, but got %v", port, got)
+	}
+
+	if _, err := d.race(context.Background(), "tcp", []string{closed}); err == nil {
+		t.Fatal("err should not be nil")
+	}
+}
+
+func TestDialNoAddress(t *testing.T) {
+	d := New(resolvertest.New(resolvertest.Zone(map[string][]dns.ResourceRecord{})))
+	if _, err := d.Dial("tcp", "nx.example:80"); err == nil {
+		t.Fatal("err should not be nil")
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("2001:db8::2"),
+		net.ParseIP("2001:db8::3"),
+		net.ParseIP("192.0.2.1"),
+	}
+	got := interleave(ips)
+	families := ""
+	for _, ip := range got {
+		if ip.To4() == nil {
+			families += "6"
+		} else {
+			families += "4"
+		}
+	}
+	if families != "6466" && families != "4666" {
+		t.Fatalf("families should be interleaved: %v", got)
+	}
+}
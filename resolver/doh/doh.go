@@ -2,11 +2,14 @@ package doh
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
-	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/nna774/zorori/dns"
 	"github.com/nna774/zorori/resolver"
@@ -16,57 +19,149 @@ import (
 
 // DoHResolver resolves by DoH
 type doHResolver struct {
-	URL string
+	implements.Methods
+	URL    string
+	client *http.Client
+
+	bootstrapIPs []net.IP
+	bootstrap    resolver.Resolver
+
+	mu    sync.Mutex
+	cache map[string]cachedAddrs
+	now   func() time.Time
+}
+
+type cachedAddrs struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+const timeout = 10 * time.Second
+
+// Option configures DoH resolver
+type Option func(*doHResolver)
+
+// WithBootstrapIPs makes resolver connect to ips instead of resolving the host of URL
+func WithBootstrapIPs(ips ...net.IP) Option {
+	return func(r *doHResolver) {
+		r.bootstrapIPs = ips
+	}
+}
+
+// WithBootstrapResolver makes resolver resolve the host of URL by bootstrap
+func WithBootstrapResolver(bootstrap resolver.Resolver) Option {
+	return func(r *doHResolver) {
+		r.bootstrap = bootstrap
+	}
 }
 
 // NewDoHResolver makes new resolver
-func NewDoHResolver(url string) resolver.Resolver {
-	return &doHResolver{URL: url}
+func NewDoHResolver(url string, opts ...Option) resolver.Resolver {
+	r := &doHResolver{
+		URL:   url,
+		cache: map[string]cachedAddrs{},
+		now:   time.Now,
+	}
+	r.Methods = implements.Methods{Resolve: r.Resolve}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         r.dialContext,
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: timeout,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	return r
+}
+
+func (r *doHResolver) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if (r.bootstrapIPs == nil && r.bootstrap == nil) || net.ParseIP(host) != nil {
+		return d.DialContext(ctx, network, addr)
+	}
+	ips, err := r.lookup(host)
+	if err != nil {
+		return nil, errors.Wrap(err, "bootstrap failed")
+	}
+	err = errors.Errorf("no address for %v", host)
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
 }
 
-// AResolve resolves A
-func (r *doHResolver) AResolve(domain string) (dns.AResult, error) {
-	query := dns.NewQuery(domain, dns.A)
+// lookup resolves host of URL by bootstrap, and caches the result by TTL.
+func (r *doHResolver) lookup(host string) ([]net.IP, error) {
+	if r.bootstrapIPs != nil {
+		return r.bootstrapIPs, nil
+	}
+	r.mu.Lock()
+	c, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && r.now().Before(c.expires) {
+		return c.ips, nil
+	}
+
+	records := []dns.AddressRecord{}
+	a, aerr := r.bootstrap.AResolve(host)
+	records = append(records, a.Records()...)
+	aaaa, aaaaerr := r.bootstrap.AAAAResolve(host)
+	records = append(records, aaaa.Records()...)
+	if aerr != nil && aaaaerr != nil {
+		return nil, aerr
+	}
+	if len(records) == 0 {
+		return nil, errors.Errorf("no address for %v", host)
+	}
+	ips := make([]net.IP, len(records))
+	ttl := records[0].TTL
+	for i, rec := range records {
+		ips[i] = rec.IP
+		if rec.TTL < ttl {
+			ttl = rec.TTL
+		}
+	}
+	resolver.SortByRFC6724(ips)
+	r.mu.Lock()
+	r.cache[host] = cachedAddrs{ips: ips, expires: r.now().Add(time.Duration(ttl) * time.Second)}
+	r.mu.Unlock()
+	return ips, nil
+}
+
+// Resolve asks domain of t
+func (r *doHResolver) Resolve(domain string, t dns.QueryType) (dns.Answer, error) {
+	query := dns.NewQuery(domain, t)
 	var buf bytes.Buffer
-	n, err := io.Copy(&buf, &query)
+	_, err := io.Copy(&buf, &query)
 	if err != nil {
-		return implements.AFail(errors.Wrap(err, "bie"))
+		return dns.Answer{}, errors.Wrap(err, "bie")
 	}
-	fmt.Printf("buf: %v\n", buf.Bytes())
-	encoded := base64.RawURLEncoding.EncodeToString(buf.Bytes()[:n+1])
+	encoded := base64.RawURLEncoding.EncodeToString(buf.Bytes())
 	q := r.URL + "?dns=" + encoded
-	fmt.Printf("encoded: %v\n", encoded)
-	res, err := http.Get(q)
+	res, err := r.client.Get(q)
 	if err != nil {
-		return implements.AFail(errors.Wrap(err, "bie"))
+		return dns.Answer{}, errors.Wrap(err, "bie")
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return dns.Answer{}, errors.Errorf("bie: %v", res.Status)
+	}
 	body, err := ioutil.ReadAll(res.Body)
-	fmt.Printf("body: %v\n", body)
-	fmt.Printf("bodys: %v\n", string(body))
-	ans, err := dns.ParseAnswer(body)
 	if err != nil {
-		return implements.AFail(err)
-	}
-	ret := dns.AResult{}
-	searching := domain
-	for _, a := range ans.Answers {
-		// これだと順序が変わると引けなくなる。
-		if dns.Same(searching, a.Name) {
-			switch a.T {
-			case dns.A:
-				ip, _ := a.IP()
-				ret = dns.NewAResult(ip)
-				break
-			case dns.CNAME:
-				searching, _ = a.CNAMETO()
-			}
-		}
+		return dns.Answer{}, errors.Wrap(err, "bie")
 	}
-
-	return ret, nil
-}
-
-func (r *doHResolver) SVCBResolve() (dns.SVCBResult, error) {
-	return dns.SVCBResult{}, nil
+	return dns.ParseAnswer(body)
 }
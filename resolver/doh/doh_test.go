@@ -0,0 +1,154 @@
+package doh
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver/resolvertest"
+)
+
+func bootstrapResolver() *resolvertest.Resolver {
+	return resolvertest.New(resolvertest.Zone(map[string][]dns.ResourceRecord{
+		"example.com.": {
+			dns.NewResourceRecord("example.com.", dns.A, dns.IN, 60, []byte{127, 0, 0, 1}),
+			dns.NewResourceRecord("example.com.", dns.AAAA, dns.IN, 30, net.ParseIP("::1")),
+		},
+	}))
+}
+
+func TestLookupTTL(t *testing.T) {
+	b := bootstrapResolver()
+	r := NewDoHResolver("https://example.com/dns-query", WithBootstrapResolver(b)).(*doHResolver)
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	ips, err := r.lookup("example.com")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if len(ips) != 2 || b.Calls() != 2 {
+		t.Fatalf("unexpected addrs: %v, %v calls", ips, b.Calls())
+	}
+	// 短い方の TTL まではキャッシュを使う。
+	now = now.Add(29 * time.Second)
+	if _, err := r.lookup("example.com"); err != nil || b.Calls() != 2 {
+		t.Fatalf("should be cached: %v, %v calls", err, b.Calls())
+	}
+	now = now.Add(2 * time.Second)
+	if _, err := r.lookup("example.com"); err != nil || b.Calls() != 4 {
+		t.Fatalf("should be expired: %v, %v calls", err, b.Calls())
+	}
+
+	if _, err := r.lookup("nothing.example"); err == nil {
+		t.Fatalf("unknown host should be error")
+	}
+}
+
+func TestLookupBootstrapIPs(t *testing.T) {
+	ip := net.ParseIP("192.0.2.1")
+	r := NewDoHResolver("https://example.com/dns-query", WithBootstrapIPs(ip)).(*doHResolver)
+	ips, err := r.lookup("example.com")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(ip) {
+		t.Fatalf("unexpected addrs: %v", ips)
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	for _, c := range []struct {
+		name string
+		opt  Option
+	}{
+		// ::1 では聞いていないので次を試す。
+		{"ips", WithBootstrapIPs(net.IPv6loopback, net.ParseIP("127.0.0.1"))},
+		{"resolver", WithBootstrapResolver(bootstrapResolver())},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewDoHResolver("https://example.com/dns-query", c.opt).(*doHResolver)
+			// ::1 は聞いていないので 127.0.0.1 に繋がる。
+			conn, err := r.dialContext(context.Background(), "tcp", net.JoinHostPort("example.com", port))
+			if err != nil {
+				t.Fatalf("err should be nil: %v", err)
+			}
+			defer conn.Close()
+			if addr := conn.RemoteAddr().String(); addr != l.Addr().String() {
+				t.Fatalf("unexpected addr: %v", addr)
+			}
+		})
+	}
+
+	r := NewDoHResolver("https://example.com/dns-query", WithBootstrapResolver(bootstrapResolver())).(*doHResolver)
+	if _, err := r.dialContext(context.Background(), "tcp", net.JoinHostPort("nothing.example", port)); err == nil {
+		t.Fatalf("unknown host should be error")
+	}
+}
+
+// serveDoH answers 192.0.2.1 to any query, the question is echoed as is.
+func serveDoH(w http.ResponseWriter, req *http.Request) {
+	q, err := base64.RawURLEncoding.DecodeString(req.URL.Query().Get("dns"))
+	if err != nil || len(q) < 12 {
+		http.Error(w, "bad query", http.StatusBadRequest)
+		return
+	}
+	end := 12
+	for q[end] != 0 {
+		end += int(q[end]) + 1
+	}
+	end += 5
+	b := append([]byte{}, q[:end]...)
+	binary.BigEndian.PutUint16(b[2:], 0x8180)
+	binary.BigEndian.PutUint16(b[6:], 1)
+	// 名前は question を指す。
+	b = append(b, 0xc0, 12, 0, byte(dns.A), 0, dns.IN, 0, 0, 1, 44, 0, 4, 192, 0, 2, 1)
+	w.Header().Set("Content-Type", "application/dns-message")
+	w.Write(b)
+}
+
+func TestResolveWithBootstrap(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(serveDoH))
+	defer ts.Close()
+
+	// 証明書は example.com のものなので名前で繋ぐ。
+	url := strings.Replace(ts.URL, "127.0.0.1", "example.com", 1)
+	r := NewDoHResolver(url, WithBootstrapIPs(net.ParseIP("127.0.0.1"))).(*doHResolver)
+	transport := r.client.Transport.(*http.Transport)
+	if transport.Proxy == nil {
+		t.Fatalf("proxy should be taken from environment")
+	}
+	transport.TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+	transport.Proxy = nil
+
+	a, err := r.AResolve("www.example.")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if !a.IP().Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("unexpected addr: %v", a.IPs())
+	}
+}
@@ -0,0 +1,120 @@
+package doh
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+)
+
+const (
+	mediaType  = "application/dns-message"
+	maxMessage = 65535
+)
+
+type handler struct {
+	resolver resolver.Resolver
+}
+
+// NewHandler makes http.Handler answering DoH queries by r. see RFC 8484
+func NewHandler(r resolver.Resolver) http.Handler {
+	return &handler{resolver: r}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var msg []byte
+	switch req.Method {
+	case http.MethodGet:
+		param := req.URL.Query().Get("dns")
+		if param == "" {
+			http.Error(w, "dns parameter is required", http.StatusBadRequest)
+			return
+		}
+		b, err := base64.RawURLEncoding.DecodeString(param)
+		if err != nil {
+			http.Error(w, "dns parameter is not base64url", http.StatusBadRequest)
+			return
+		}
+		msg = b
+	case http.MethodPost:
+		// parameter が付いていてもよい。
+		if t, _, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err != nil || t != mediaType {
+			http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+			return
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(req.Body, maxMessage+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(b) > maxMessage {
+			http.Error(w, "message too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		msg = b
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := dns.ParseQuery(msg)
+	var ans dns.Answer
+	switch err {
+	case nil:
+		ans = resolver.Forward(h.resolver, q)
+	case dns.ErrNotImp:
+		ans = dns.NewErrorAnswer(q, dns.NotImp)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	b, err := ans.Pack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	if ttl, ok := minTTL(ans); ok {
+		w.Header().Set("Cache-Control", "max-age="+strconv.FormatUint(uint64(ttl), 10))
+	}
+	w.Write(b)
+}
+
+// minTTL returns the least TTL of answers, or the negative caching TTL of SOA for negative answers. see RFC 8484 5.1
+func minTTL(ans dns.Answer) (uint32, bool) {
+	if len(ans.Answers) == 0 {
+		return negativeTTL(ans)
+	}
+	found := false
+	var ttl uint32
+	for _, rr := range ans.Answers {
+		if !found || rr.TTL < ttl {
+			ttl = rr.TTL
+			found = true
+		}
+	}
+	return ttl, found
+}
+
+// negativeTTL returns the smaller of TTL and MINIMUM of SOA in authorities. see RFC 2308 5
+func negativeTTL(ans dns.Answer) (uint32, bool) {
+	for _, rr := range ans.Authorities {
+		// MINIMUM は rdata の末尾 4 byte で、名前が圧縮されていても位置は変わらない。
+		if rr.T != dns.SOA || len(rr.Rdata) < 22 {
+			continue
+		}
+		ttl := rr.TTL
+		if minimum := binary.BigEndian.Uint32(rr.Rdata[len(rr.Rdata)-4:]); minimum < ttl {
+			ttl = minimum
+		}
+		return ttl, true
+	}
+	return 0, false
+}
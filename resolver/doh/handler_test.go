@@ -0,0 +1,150 @@
+package doh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver/resolvertest"
+)
+
+// soa makes SOA of example. whose MINIMUM is minimum.
+func soa(ttl, minimum uint32) dns.ResourceRecord {
+	rdata := append(dns.PackName("ns.example."), dns.PackName("hostmaster.example.")...)
+	fixed := make([]byte, 20)
+	binary.BigEndian.PutUint32(fixed[16:], minimum)
+	return dns.NewResourceRecord("example.", dns.SOA, dns.IN, ttl, append(rdata, fixed...))
+}
+
+func newTestServer() *httptest.Server {
+	zone := resolvertest.Zone(map[string][]dns.ResourceRecord{
+		"www.example.": {
+			dns.NewResourceRecord("www.example.", dns.A, dns.IN, 300, []byte{192, 0, 2, 1}),
+			dns.NewResourceRecord("www.example.", dns.A, dns.IN, 60, []byte{192, 0, 2, 2}),
+		},
+	})
+	return httptest.NewServer(NewHandler(resolvertest.New(func(name string, t dns.QueryType) (dns.Answer, error) {
+		ans, err := zone(name, t)
+		switch {
+		case dns.Same(name, "nx.example."):
+			ans.Authorities = append(ans.Authorities, soa(3600, 120))
+		case dns.Same(name, "short.example."):
+			ans.Authorities = append(ans.Authorities, soa(30, 120))
+		}
+		return ans, err
+	})))
+}
+
+func TestHandlerGet(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+
+	// 自前の DoH resolver で問い合わせる。
+	a, err := NewDoHResolver(ts.URL).AResolve("www.example")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if len(a.IPs()) != 2 || !a.IP().Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("unexpected addrs: %v", a.IPs())
+	}
+
+	res, err := http.Get(ts.URL + "?dns=AAABAAABAAAAAAAAA3d3dwdleGFtcGxlAAABAAE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if cc := res.Header.Get("Cache-Control"); cc != "max-age=60" {
+		t.Fatalf("expected: max-age=60, but got %v", cc)
+	}
+}
+
+func TestHandlerPost(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+
+	query := dns.NewQuery("www.example", dns.A)
+	var buf bytes.Buffer
+	io.Copy(&buf, &query)
+	res, err := http.Post(ts.URL, mediaType+"; charset=binary", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != mediaType {
+		t.Fatalf("unexpected response: %v", res.Status)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	ans, err := dns.ParseAnswer(body)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if ans.Header.ID() != query.Header.ID() || len(ans.Answers) != 2 {
+		t.Fatalf("unexpected answer: %v", ans)
+	}
+}
+
+func TestHandlerNegativeTTL(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"nx.example.", "max-age=120"},
+		{"short.example.", "max-age=30"},
+		{"other.example.", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			query := dns.NewQuery(c.name, dns.A)
+			var buf bytes.Buffer
+			io.Copy(&buf, &query)
+			res, err := http.Get(ts.URL + "?dns=" + base64.RawURLEncoding.EncodeToString(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if cc := res.Header.Get("Cache-Control"); cc != c.expected {
+				t.Fatalf("expected: %q, but got %q", c.expected, cc)
+			}
+		})
+	}
+}
+
+func TestHandlerBadRequest(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+	cases := []struct {
+		name   string
+		req    func() (*http.Response, error)
+		status int
+	}{
+		{"no param", func() (*http.Response, error) { return http.Get(ts.URL) }, http.StatusBadRequest},
+		{"not base64", func() (*http.Response, error) { return http.Get(ts.URL + "?dns=!!") }, http.StatusBadRequest},
+		{"malformed", func() (*http.Response, error) { return http.Get(ts.URL + "?dns=AAABAAAB") }, http.StatusBadRequest},
+		{"media type", func() (*http.Response, error) { return http.Post(ts.URL, "text/plain", &bytes.Buffer{}) }, http.StatusUnsupportedMediaType},
+		{"method", func() (*http.Response, error) {
+			req, _ := http.NewRequest(http.MethodPut, ts.URL, nil)
+			return http.DefaultClient.Do(req)
+		}, http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := c.req()
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != c.status {
+				t.Fatalf("expected: %v, but got %v", c.status, res.StatusCode)
+			}
+		})
+	}
+}
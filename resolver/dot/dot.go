@@ -0,0 +1,78 @@
+package dot
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/implements"
+	"github.com/pkg/errors"
+)
+
+const timeout = 5 * time.Second
+
+// dotResolver resolves by DNS over TLS (RFC 7858)
+type dotResolver struct {
+	implements.Methods
+	addr       string
+	serverName string
+	// rootCAs は nil ならシステムのものを使う。
+	rootCAs *x509.CertPool
+}
+
+// NewDoTResolver makes new resolver, addr is host:port and serverName is verified with the certificate
+func NewDoTResolver(addr, serverName string) resolver.Resolver {
+	r := &dotResolver{addr: addr, serverName: serverName}
+	r.Methods = implements.Methods{Resolve: r.Resolve}
+	return r
+}
+
+// Resolve asks domain of t
+func (r *dotResolver) Resolve(domain string, t dns.QueryType) (dns.Answer, error) {
+	query := dns.NewQuery(domain, t)
+	var buf bytes.Buffer
+	_, err := io.Copy(&buf, &query)
+	if err != nil {
+		return dns.Answer{}, errors.Wrap(err, "bie")
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", r.addr, &tls.Config{
+		ServerName: r.serverName,
+		NextProtos: []string{"dot"},
+		RootCAs:    r.rootCAs,
+	})
+	if err != nil {
+		return dns.Answer{}, errors.Wrap(err, "bie")
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// 長さ 2 byte を前に付ける。
+	msg := make([]byte, 2, 2+buf.Len())
+	binary.BigEndian.PutUint16(msg, uint16(buf.Len()))
+	msg = append(msg, buf.Bytes()...)
+	if _, err := conn.Write(msg); err != nil {
+		return dns.Answer{}, errors.Wrap(err, "bie")
+	}
+	length := make([]byte, 2)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return dns.Answer{}, errors.Wrap(err, "bie")
+	}
+	body := make([]byte, binary.BigEndian.Uint16(length))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return dns.Answer{}, errors.Wrap(err, "bie")
+	}
+	ans, err := dns.ParseAnswer(body)
+	if err != nil {
+		return ans, err
+	}
+	if ans.Header.ID() != query.Header.ID() {
+		return dns.Answer{}, errors.New("id mismatch")
+	}
+	return ans, nil
+}
@@ -0,0 +1,102 @@
+package dot
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nna774/zorori/dns"
+)
+
+// listenTLS serves one query per connection with the certificate of httptest, which is valid for example.com.
+func listenTLS(t *testing.T) (net.Listener, *x509.CertPool) {
+	hs := httptest.NewTLSServer(http.NotFoundHandler())
+	hs.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(hs.Certificate())
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: hs.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn)
+		}
+	}()
+	return l, pool
+}
+
+func serveConn(conn net.Conn) {
+	defer conn.Close()
+	l := make([]byte, 2)
+	if _, err := io.ReadFull(conn, l); err != nil {
+		return
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(l))
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return
+	}
+	b := reply(msg)
+	binary.BigEndian.PutUint16(l, uint16(len(b)))
+	conn.Write(append(l, b...))
+}
+
+// reply answers 192.0.2.1 to query q, the question is echoed as is.
+func reply(q []byte) []byte {
+	end := 12
+	for q[end] != 0 {
+		end += int(q[end]) + 1
+	}
+	end += 5
+	b := append([]byte{}, q[:end]...)
+	// QR と RD, RA を立てる。
+	binary.BigEndian.PutUint16(b[2:], 0x8180)
+	binary.BigEndian.PutUint16(b[6:], 1)
+	// 名前は question を指す。
+	rr := []byte{0xc0, 12, 0, byte(dns.A), 0, dns.IN, 0, 0, 1, 44, 0, 4, 192, 0, 2, 1}
+	return append(b, rr...)
+}
+
+func testDoT(addr, serverName string, rootCAs *x509.CertPool) *dotResolver {
+	r := NewDoTResolver(addr, serverName).(*dotResolver)
+	r.rootCAs = rootCAs
+	return r
+}
+
+func TestResolve(t *testing.T) {
+	l, pool := listenTLS(t)
+	defer l.Close()
+
+	r := testDoT(l.Addr().String(), "example.com", pool)
+	a, err := r.AResolve("www.example.")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if !a.IP().Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("unexpected addr: %v", a.IPs())
+	}
+}
+
+func TestVerifyServerName(t *testing.T) {
+	l, pool := listenTLS(t)
+	defer l.Close()
+
+	r := testDoT(l.Addr().String(), "dns.example.net", pool)
+	if _, err := r.AResolve("www.example."); err == nil {
+		t.Fatalf("certificate for other name should be rejected")
+	}
+	r = testDoT(l.Addr().String(), "example.com", nil)
+	if _, err := r.AResolve("www.example."); err == nil {
+		t.Fatalf("certificate not in system roots should be rejected")
+	}
+}
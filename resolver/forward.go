@@ -0,0 +1,14 @@
+package resolver
+
+import "github.com/nna774/zorori/dns"
+
+// Forward resolves q by r and returns the response for the client, SERVFAIL if r fails
+func Forward(r Resolver, q dns.Query) dns.Answer {
+	ans, err := r.Resolve(q.Question.Name(), q.Question.Type())
+	if err != nil {
+		ans = dns.NewAnswer(q.Question)
+		ans.Header.SetRCode(dns.ServFail)
+	}
+	ans.ReplyTo(q)
+	return ans
+}
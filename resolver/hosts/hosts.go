@@ -0,0 +1,152 @@
+package hosts
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/implements"
+)
+
+// Hosts is parsed hosts file
+type Hosts struct {
+	addrs map[string][]net.IP
+	names map[string][]string
+}
+
+// Load reads hosts file at path
+func Load(path string) (*Hosts, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads hosts file
+func Parse(r io.Reader) (*Hosts, error) {
+	h := &Hosts{
+		addrs: map[string][]net.IP{},
+		names: map[string][]string{},
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(strings.SplitN(fields[0], "%", 2)[0])
+		if ip == nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			key := dns.Normalize(name)
+			h.addrs[key] = append(h.addrs[key], ip)
+			h.names[ip.String()] = append(h.names[ip.String()], dns.Fqdn(name))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// LookupName returns addrs of name
+func (h *Hosts) LookupName(name string) []net.IP {
+	return h.addrs[dns.Normalize(name)]
+}
+
+// LookupAddr returns names of ip, canonical name first
+func (h *Hosts) LookupAddr(ip net.IP) []string {
+	return h.names[ip.String()]
+}
+
+type hostsResolver struct {
+	implements.Methods
+	path string
+	next resolver.Resolver
+
+	mu      sync.Mutex
+	modTime time.Time
+	hosts   *Hosts
+}
+
+// New makes resolver which looks up hosts file at path before next, the file is reloaded when it changes
+func New(path string, next resolver.Resolver) resolver.Resolver {
+	r := &hostsResolver{
+		path:  path,
+		next:  next,
+		hosts: &Hosts{},
+	}
+	r.Methods = implements.Methods{Resolve: r.Resolve}
+	r.reload()
+	return r
+}
+
+// reload reads the file again if it has changed.
+func (r *hostsResolver) reload() {
+	st, err := os.Stat(r.path)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		// 消えたら空として扱う。
+		r.hosts = &Hosts{}
+		r.modTime = time.Time{}
+		return
+	}
+	if st.ModTime().Equal(r.modTime) {
+		return
+	}
+	hosts, err := Load(r.path)
+	if err != nil {
+		return
+	}
+	r.hosts = hosts
+	r.modTime = st.ModTime()
+}
+
+func (r *hostsResolver) current() *Hosts {
+	r.reload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hosts
+}
+
+// Resolve answers A, AAAA and PTR from hosts file, and asks others to next
+func (r *hostsResolver) Resolve(name string, t dns.QueryType) (dns.Answer, error) {
+	if ip, ok := dns.ParseReverseName(name); ok && t == dns.PTR {
+		ans := dns.NewAnswer(dns.NewQuestion(name, t))
+		for _, n := range r.current().LookupAddr(ip) {
+			ans.Answers = append(ans.Answers, dns.NewResourceRecord(dns.Fqdn(name), dns.PTR, dns.IN, 0, dns.PackName(n)))
+		}
+		if len(ans.Answers) > 0 {
+			return ans, nil
+		}
+	}
+	if t == dns.A || t == dns.AAAA {
+		ans := dns.NewAnswer(dns.NewQuestion(name, t))
+		for _, ip := range r.current().LookupName(name) {
+			switch {
+			case t == dns.A && ip.To4() != nil:
+				ans.Answers = append(ans.Answers, dns.NewResourceRecord(dns.Fqdn(name), dns.A, dns.IN, 0, ip.To4()))
+			case t == dns.AAAA && ip.To4() == nil:
+				ans.Answers = append(ans.Answers, dns.NewResourceRecord(dns.Fqdn(name), dns.AAAA, dns.IN, 0, ip.To16()))
+			}
+		}
+		if len(ans.Answers) > 0 {
+			return ans, nil
+		}
+	}
+	return r.next.Resolve(name, t)
+}
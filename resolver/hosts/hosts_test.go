@@ -0,0 +1,97 @@
+package hosts
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver/resolvertest"
+)
+
+// nextResolver answers 198.51.100.1 for any name.
+func nextResolver() *resolvertest.Resolver {
+	return resolvertest.New(func(name string, t dns.QueryType) (dns.Answer, error) {
+		ans := dns.NewAnswer(dns.NewQuestion(name, t))
+		ans.Answers = []dns.ResourceRecord{dns.NewResourceRecord(dns.Fqdn(name), dns.A, dns.IN, 300, []byte{198, 51, 100, 1})}
+		return ans, nil
+	})
+}
+
+func TestLoad(t *testing.T) {
+	h, err := Load("testdata/hosts")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	ips := h.LookupName("DB.corp.example.")
+	if len(ips) != 3 || !ips[0].Equal(net.ParseIP("192.0.2.10")) {
+		t.Fatalf("unexpected addrs: %v", ips)
+	}
+	if ips := h.LookupName("link.local"); len(ips) != 1 {
+		t.Fatalf("zone should be dropped: %v", ips)
+	}
+	if ips := h.LookupName("bad.example"); len(ips) != 0 {
+		t.Fatalf("bad line should be skipped: %v", ips)
+	}
+	names := h.LookupAddr(net.ParseIP("192.0.2.10"))
+	if len(names) != 2 || names[0] != "db.corp.example." {
+		t.Fatalf("unexpected names: %v", names)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	next := nextResolver()
+	r := New("testdata/hosts", next)
+	a, err := r.AResolve("db.corp.example")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if len(a.IPs()) != 2 || next.Calls() != 0 {
+		t.Fatalf("should be answered from hosts: %v", a.IPs())
+	}
+	aaaa, _ := r.AAAAResolve("db.corp.example")
+	if len(aaaa.IPs()) != 1 || !aaaa.IP().Equal(net.ParseIP("2001:db8::10")) {
+		t.Fatalf("unexpected AAAA: %v", aaaa.IPs())
+	}
+	a, _ = r.AResolve("www.example.com")
+	if next.Calls() != 1 || !a.IP().Equal(net.ParseIP("198.51.100.1")) {
+		t.Fatalf("should be asked to next: %v", a.IPs())
+	}
+	ptr, err := r.ReverseResolve(net.ParseIP("192.0.2.10"))
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if names := ptr.Names(); len(names) != 2 || names[0] != "db.corp.example." || next.Calls() != 1 {
+		t.Fatalf("unexpected PTR: %v", names)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hosts")
+	if err := ioutil.WriteFile(path, []byte("192.0.2.1 app.example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New(path, nextResolver())
+	a, _ := r.AResolve("app.example")
+	if !a.IP().Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("unexpected addr: %v", a.IPs())
+	}
+
+	if err := ioutil.WriteFile(path, []byte("192.0.2.2 app.example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	a, _ = r.AResolve("app.example")
+	if !a.IP().Equal(net.ParseIP("192.0.2.2")) {
+		t.Fatalf("should be reloaded: %v", a.IPs())
+	}
+}
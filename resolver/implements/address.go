@@ -0,0 +1,57 @@
+package implements
+
+import (
+	"github.com/nna774/zorori/dns"
+)
+
+// ResolveA resolves A of domain by resolve
+func ResolveA(resolve ResolveFunc, domain string) (dns.AResult, error) {
+	ans, err := resolve(domain, dns.A)
+	if err != nil {
+		return AFail(err)
+	}
+	return AResultOf(ans, domain)
+}
+
+// ResolveAAAA resolves AAAA of domain by resolve
+func ResolveAAAA(resolve ResolveFunc, domain string) (dns.AAAAResult, error) {
+	ans, err := resolve(domain, dns.AAAA)
+	if err != nil {
+		return AAAAFail(err)
+	}
+	return AAAAResultOf(ans, domain)
+}
+
+// AResultOf makes AResult of domain from ans
+func AResultOf(ans dns.Answer, domain string) (dns.AResult, error) {
+	name, records, err := addressRecords(ans, domain, dns.A)
+	if err != nil {
+		return AFail(err)
+	}
+	return dns.NewAResult(name, records), nil
+}
+
+// AAAAResultOf makes AAAAResult of domain from ans
+func AAAAResultOf(ans dns.Answer, domain string) (dns.AAAAResult, error) {
+	name, records, err := addressRecords(ans, domain, dns.AAAA)
+	if err != nil {
+		return AAAAFail(err)
+	}
+	return dns.NewAAAAResult(name, records), nil
+}
+
+func addressRecords(ans dns.Answer, domain string, t dns.QueryType) (string, []dns.AddressRecord, error) {
+	name, rrs, err := dns.Chase(ans.Answers, domain, t)
+	if err != nil {
+		return "", nil, err
+	}
+	records := make([]dns.AddressRecord, 0, len(rrs))
+	for _, rr := range rrs {
+		ip, err := rr.IP()
+		if err != nil {
+			return "", nil, err
+		}
+		records = append(records, dns.AddressRecord{IP: ip, TTL: rr.TTL})
+	}
+	return name, records, nil
+}
@@ -7,7 +7,17 @@ func AFail(err error) (dns.AResult, error) {
 	return dns.AResult{}, err
 }
 
-// SVCBFail create empty SVCBResult and err
-func SVCBFail(err error) (dns.SVCBResult, error) {
-	return dns.SVCBResult{}, err
+// AAAAFail create empty AAAAResult and err
+func AAAAFail(err error) (dns.AAAAResult, error) {
+	return dns.AAAAResult{}, err
+}
+
+// SVCBFail create empty SVCBResults and err
+func SVCBFail(err error) ([]dns.SVCBResult, error) {
+	return nil, err
+}
+
+// PTRFail create empty PTRResult and err
+func PTRFail(err error) (dns.PTRResult, error) {
+	return dns.PTRResult{}, err
 }
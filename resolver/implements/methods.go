@@ -0,0 +1,37 @@
+package implements
+
+import (
+	"net"
+
+	"github.com/nna774/zorori/dns"
+)
+
+// Methods provides typed resolve methods on top of Resolve, embed it and set Resolve in ctor
+type Methods struct {
+	Resolve ResolveFunc
+}
+
+// AResolve resolves A
+func (m Methods) AResolve(domain string) (dns.AResult, error) {
+	return ResolveA(m.Resolve, domain)
+}
+
+// AAAAResolve resolves AAAA
+func (m Methods) AAAAResolve(domain string) (dns.AAAAResult, error) {
+	return ResolveAAAA(m.Resolve, domain)
+}
+
+// SVCBResolve resolves SVCB
+func (m Methods) SVCBResolve(name string) ([]dns.SVCBResult, error) {
+	return ResolveSVCB(m.Resolve, name, dns.SVCB)
+}
+
+// HTTPSResolve resolves HTTPS
+func (m Methods) HTTPSResolve(name string) ([]dns.SVCBResult, error) {
+	return ResolveSVCB(m.Resolve, name, dns.HTTPS)
+}
+
+// ReverseResolve resolves PTR of ip
+func (m Methods) ReverseResolve(ip net.IP) (dns.PTRResult, error) {
+	return ResolvePTR(m.Resolve, ip)
+}
@@ -0,0 +1,34 @@
+package implements
+
+import (
+	"net"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/pkg/errors"
+)
+
+// ResolvePTR resolves PTR of ip by resolve
+func ResolvePTR(resolve ResolveFunc, ip net.IP) (dns.PTRResult, error) {
+	name := dns.ReverseName(ip)
+	if name == "" {
+		return PTRFail(errors.Errorf("invalid ip: %v", ip))
+	}
+	ans, err := resolve(name, dns.PTR)
+	if err != nil {
+		return PTRFail(err)
+	}
+	// classless delegation (RFC 2317) では CNAME を辿ることになる。
+	_, rrs, err := dns.Chase(ans.Answers, name, dns.PTR)
+	if err != nil {
+		return PTRFail(err)
+	}
+	names := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		n, err := rr.PTRName()
+		if err != nil {
+			return PTRFail(err)
+		}
+		names = append(names, n)
+	}
+	return dns.NewPTRResult(names), nil
+}
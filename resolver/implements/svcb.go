@@ -0,0 +1,70 @@
+package implements
+
+import (
+	"sort"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/pkg/errors"
+)
+
+// ResolveFunc asks name of the type and returns the response
+type ResolveFunc func(string, dns.QueryType) (dns.Answer, error)
+
+const maxAliasChain = 8
+
+var errAliasLoop = errors.New("SVCB alias loop")
+
+// ResolveSVCB resolves SVCB or HTTPS of name, following AliasMode. see RFC 9460
+func ResolveSVCB(resolve ResolveFunc, name string, t dns.QueryType) ([]dns.SVCBResult, error) {
+	searching := name
+	visited := map[string]bool{}
+	for i := 0; i < maxAliasChain; i++ {
+		if visited[dns.Normalize(searching)] {
+			return SVCBFail(errAliasLoop)
+		}
+		visited[dns.Normalize(searching)] = true
+
+		ans, err := resolve(searching, t)
+		if err != nil {
+			return SVCBFail(err)
+		}
+		owner, rrs, err := dns.Chase(ans.Answers, searching, t)
+		if err != nil {
+			return SVCBFail(err)
+		}
+		results := make([]dns.SVCBResult, 0, len(rrs))
+		var alias *dns.SVCBResult
+		for _, rr := range rrs {
+			r, err := rr.SVCB()
+			if err != nil {
+				return SVCBFail(err)
+			}
+			if r.AliasMode() {
+				alias = &r
+				continue
+			}
+			if r.Target == "." {
+				// ServiceMode の "." は owner そのもの。
+				r.Target = owner
+			}
+			results = append(results, r)
+		}
+		if alias == nil && len(results) == 0 && i > 0 {
+			// alias の先に SVCB が無ければ、その先の A/AAAA を使う。 see RFC 9460 2.4.2
+			return []dns.SVCBResult{{Name: searching, Priority: 1, Target: searching, Params: dns.SvcParams{}}}, nil
+		}
+		if alias == nil {
+			sort.SliceStable(results, func(i, j int) bool {
+				return results[i].Priority < results[j].Priority
+			})
+			return results, nil
+		}
+		// AliasMode があれば ServiceMode は無視する。
+		if alias.Target == "." {
+			// サービスは無い。
+			return []dns.SVCBResult{}, nil
+		}
+		searching = alias.Target
+	}
+	return SVCBFail(errAliasLoop)
+}
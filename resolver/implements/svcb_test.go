@@ -0,0 +1,69 @@
+package implements
+
+import (
+	"testing"
+
+	"github.com/nna774/zorori/dns"
+)
+
+func svcbRR(owner string, priority uint16, target string) dns.ResourceRecord {
+	rdata := append([]byte{byte(priority >> 8), byte(priority)}, dns.PackName(target)...)
+	return dns.NewResourceRecord(owner, dns.HTTPS, dns.IN, 300, rdata)
+}
+
+func TestResolveSVCBAlias(t *testing.T) {
+	zone := map[string][]dns.ResourceRecord{
+		"example.com.":     {svcbRR("example.com.", 0, "svc.example.net.")},
+		"svc.example.net.": {svcbRR("svc.example.net.", 2, "."), svcbRR("svc.example.net.", 1, "pool.example.net.")},
+	}
+	resolve := func(name string, t dns.QueryType) (dns.Answer, error) {
+		return dns.Answer{Answers: zone[dns.Normalize(name)]}, nil
+	}
+	res, err := ResolveSVCB(resolve, "example.com", dns.HTTPS)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected 2 results, but got %v", res)
+	}
+	if res[0].Priority != 1 || res[0].Target != "pool.example.net." {
+		t.Fatalf("unexpected first result: %v", res[0])
+	}
+	if res[1].Target != "svc.example.net." {
+		t.Fatalf("\".\" target should be owner, but got %v", res[1].Target)
+	}
+}
+
+func TestResolveSVCBAliasLoop(t *testing.T) {
+	zone := map[string][]dns.ResourceRecord{
+		"a.example.": {svcbRR("a.example.", 0, "b.example.")},
+		"b.example.": {svcbRR("b.example.", 0, "a.example.")},
+	}
+	resolve := func(name string, t dns.QueryType) (dns.Answer, error) {
+		return dns.Answer{Answers: zone[dns.Normalize(name)]}, nil
+	}
+	if _, err := ResolveSVCB(resolve, "a.example.", dns.HTTPS); err == nil {
+		t.Fatalf("alias loop should be error")
+	}
+}
+
+func TestResolveSVCBAliasWithoutService(t *testing.T) {
+	zone := map[string][]dns.ResourceRecord{
+		"example.com.": {svcbRR("example.com.", 0, "cdn.example.net.")},
+	}
+	resolve := func(name string, t dns.QueryType) (dns.Answer, error) {
+		return dns.Answer{Answers: zone[dns.Normalize(name)]}, nil
+	}
+	res, err := ResolveSVCB(resolve, "example.com", dns.HTTPS)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if len(res) != 1 || res[0].AliasMode() || res[0].Target != "cdn.example.net." {
+		t.Fatalf("alias target should be kept as ServiceMode: %v", res)
+	}
+
+	// alias が無ければ何も作らない。
+	if res, _ := ResolveSVCB(resolve, "www.example.com", dns.HTTPS); len(res) != 0 {
+		t.Fatalf("expected no results, but got %v", res)
+	}
+}
@@ -0,0 +1,41 @@
+package resolver
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// LookupIP resolves A and AAAA in parallel, and returns addrs in RFC 6724 order
+func LookupIP(r Resolver, name string) ([]net.IP, error) {
+	type result struct {
+		ips []net.IP
+		err error
+	}
+	v4 := make(chan result, 1)
+	v6 := make(chan result, 1)
+	go func() {
+		res, err := r.AResolve(name)
+		v4 <- result{ips: res.IPs(), err: err}
+	}()
+	go func() {
+		res, err := r.AAAAResolve(name)
+		v6 <- result{ips: res.IPs(), err: err}
+	}()
+
+	ips := []net.IP{}
+	var errs []error
+	for _, c := range []chan result{v6, v4} {
+		res := <-c
+		if res.err != nil {
+			errs = append(errs, res.err)
+			continue
+		}
+		ips = append(ips, res.ips...)
+	}
+	if len(errs) == 2 {
+		return nil, errors.Wrap(errs[0], "lookup failed")
+	}
+	SortByRFC6724(ips)
+	return ips, nil
+}
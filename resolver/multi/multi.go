@@ -0,0 +1,246 @@
+package multi
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/implements"
+	"github.com/pkg/errors"
+)
+
+// Strategy is how to choose upstreams
+type Strategy int
+
+const (
+	// Failover asks upstreams in order, and next one only if failed
+	Failover Strategy = iota
+	// RoundRobin rotates upstreams for each query
+	RoundRobin
+	// Fastest asks the upstream with the lowest smoothed RTT first
+	Fastest
+	// Race asks all upstreams at once, and takes the first good answer
+	Race
+)
+
+// ParseStrategy parses name of strategy
+func ParseStrategy(s string) (Strategy, error) {
+	switch s {
+	case "failover":
+		return Failover, nil
+	case "roundrobin":
+		return RoundRobin, nil
+	case "fastest":
+		return Fastest, nil
+	case "race":
+		return Race, nil
+	default:
+		return Failover, errors.Errorf("unknown strategy: %v", s)
+	}
+}
+
+const (
+	defaultMaxFailures = 3
+	defaultEjectFor    = 30 * time.Second
+	// 失敗したら srtt をこれ以上に引き上げる。
+	minPenalty = time.Second
+	maxPenalty = 10 * time.Second
+)
+
+var errNoUpstreams = errors.New("no upstreams")
+
+type upstream struct {
+	resolver.Resolver
+	srtt         time.Duration
+	measured     bool
+	failures     int
+	ejectedUntil time.Time
+}
+
+type multiResolver struct {
+	implements.Methods
+	strategy    Strategy
+	upstreams   []*upstream
+	maxFailures int
+	ejectFor    time.Duration
+
+	mu   sync.Mutex
+	next int
+	now  func() time.Time
+}
+
+// Option configures multi resolver
+type Option func(*multiResolver)
+
+// WithEjection ejects an upstream for d after maxFailures consecutive failures
+func WithEjection(maxFailures int, d time.Duration) Option {
+	return func(m *multiResolver) {
+		m.maxFailures = maxFailures
+		m.ejectFor = d
+	}
+}
+
+// New makes resolver over upstreams
+func New(strategy Strategy, upstreams []resolver.Resolver, opts ...Option) resolver.Resolver {
+	m := &multiResolver{
+		strategy:    strategy,
+		maxFailures: defaultMaxFailures,
+		ejectFor:    defaultEjectFor,
+		now:         time.Now,
+	}
+	m.Methods = implements.Methods{Resolve: m.Resolve}
+	for _, u := range upstreams {
+		m.upstreams = append(m.upstreams, &upstream{Resolver: u})
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Resolve asks name of t to upstreams by the strategy
+func (m *multiResolver) Resolve(name string, t dns.QueryType) (dns.Answer, error) {
+	order := m.order()
+	if len(order) == 0 {
+		return dns.Answer{}, errNoUpstreams
+	}
+	if m.strategy == Race {
+		return m.race(order, name, t)
+	}
+	var lastErr error
+	for _, u := range order {
+		ans, err := m.try(u, name, t)
+		if err == nil {
+			return ans, nil
+		}
+		lastErr = err
+	}
+	return dns.Answer{}, lastErr
+}
+
+func (m *multiResolver) race(order []*upstream, name string, t dns.QueryType) (dns.Answer, error) {
+	type result struct {
+		ans dns.Answer
+		err error
+	}
+	// 生きているものだけで競争する。全部死んでいたら全部に聞く。
+	healthy := m.healthyCount(order)
+	if healthy > 0 {
+		order = order[:healthy]
+	}
+	results := make(chan result, len(order))
+	for _, u := range order {
+		go func(u *upstream) {
+			ans, err := m.try(u, name, t)
+			results <- result{ans: ans, err: err}
+		}(u)
+	}
+	var lastErr error
+	for range order {
+		r := <-results
+		if r.err == nil {
+			return r.ans, nil
+		}
+		lastErr = r.err
+	}
+	return dns.Answer{}, lastErr
+}
+
+func (m *multiResolver) try(u *upstream, name string, t dns.QueryType) (dns.Answer, error) {
+	start := m.now()
+	ans, err := u.Resolve(name, t)
+	if err == nil {
+		if rcode := ans.Header.RCode(); rcode == dns.ServFail || rcode == dns.Refused {
+			err = errors.Errorf("upstream answered rcode %v", rcode)
+		}
+	}
+	m.record(u, m.now().Sub(start), err)
+	return ans, err
+}
+
+// record updates health of u. srtt is smoothed like TCP (RFC 6298), and doubled on failure.
+func (m *multiResolver) record(u *upstream, rtt time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		u.failures++
+		if u.failures >= m.maxFailures {
+			u.ejectedUntil = m.now().Add(m.ejectFor)
+		}
+		u.srtt = penalty(u.srtt, rtt)
+		u.measured = true
+		return
+	}
+	u.failures = 0
+	u.ejectedUntil = time.Time{}
+	if !u.measured {
+		u.srtt = rtt
+		u.measured = true
+		return
+	}
+	u.srtt = (7*u.srtt + rtt) / 8
+}
+
+// penalty returns srtt after a failure
+func penalty(srtt, rtt time.Duration) time.Duration {
+	// すぐ失敗するものが速く見えないようにする。
+	p := 2 * srtt
+	if p < rtt {
+		p = rtt
+	}
+	if p < minPenalty {
+		p = minPenalty
+	}
+	if p > maxPenalty {
+		p = maxPenalty
+	}
+	return p
+}
+
+func (m *multiResolver) healthyCount(order []*upstream) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	n := 0
+	for _, u := range order {
+		if !now.Before(u.ejectedUntil) {
+			n++
+		}
+	}
+	return n
+}
+
+// order returns healthy upstreams by the strategy, and ejected ones as last resort.
+func (m *multiResolver) order() []*upstream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	healthy := []*upstream{}
+	ejected := []*upstream{}
+	for _, u := range m.upstreams {
+		if now.Before(u.ejectedUntil) {
+			ejected = append(ejected, u)
+			continue
+		}
+		healthy = append(healthy, u)
+	}
+	switch m.strategy {
+	case RoundRobin:
+		if len(healthy) > 0 {
+			n := m.next % len(healthy)
+			m.next++
+			healthy = append(healthy[n:], healthy[:n]...)
+		}
+	case Fastest:
+		// まだ測っていないものを先に試す。
+		sort.SliceStable(healthy, func(i, j int) bool {
+			if healthy[i].measured != healthy[j].measured {
+				return !healthy[i].measured
+			}
+			return healthy[i].srtt < healthy[j].srtt
+		})
+	}
+	return append(healthy, ejected...)
+}
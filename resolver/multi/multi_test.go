@@ -0,0 +1,96 @@
+package multi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/resolvertest"
+)
+
+// fake answers CNAME to name after delay, or err.
+func fake(name string, delay time.Duration, err error) *resolvertest.Resolver {
+	cname := resolvertest.CNAME(name)
+	return resolvertest.New(func(q string, t dns.QueryType) (dns.Answer, error) {
+		time.Sleep(delay)
+		if err != nil {
+			return dns.Answer{}, err
+		}
+		return cname(q, t)
+	})
+}
+
+func answeredBy(t *testing.T, r resolver.Resolver) string {
+	ans, err := r.Resolve("example.com.", dns.A)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	target, _ := ans.Answers[0].CNAMETO()
+	return target
+}
+
+func TestFailover(t *testing.T) {
+	broken := fake("broken.", 0, errors.New("bie"))
+	good := fake("good.", 0, nil)
+	m := New(Failover, []resolver.Resolver{broken, good}, WithEjection(2, time.Minute))
+	for i := 0; i < 3; i++ {
+		if by := answeredBy(t, m); by != "good." {
+			t.Fatalf("expected good., but got %v", by)
+		}
+	}
+	// 2 回失敗したら外される。
+	if broken.Calls() != 2 {
+		t.Fatalf("broken upstream should be ejected, but called %v times", broken.Calls())
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	a := fake("a.", 0, nil)
+	b := fake("b.", 0, nil)
+	m := New(RoundRobin, []resolver.Resolver{a, b})
+	got := []string{answeredBy(t, m), answeredBy(t, m), answeredBy(t, m)}
+	if got[0] != "a." || got[1] != "b." || got[2] != "a." {
+		t.Fatalf("unexpected order: %v", got)
+	}
+}
+
+func TestFastest(t *testing.T) {
+	slow := fake("slow.", 20*time.Millisecond, nil)
+	fast := fake("fast.", 0, nil)
+	m := New(Fastest, []resolver.Resolver{slow, fast})
+	// 最初は測定のために順に聞く。
+	answeredBy(t, m)
+	answeredBy(t, m)
+	for i := 0; i < 3; i++ {
+		if by := answeredBy(t, m); by != "fast." {
+			t.Fatalf("expected fast., but got %v", by)
+		}
+	}
+}
+
+func TestFastestPenalty(t *testing.T) {
+	broken := fake("broken.", 0, errors.New("bie"))
+	good := fake("good.", 5*time.Millisecond, nil)
+	m := New(Fastest, []resolver.Resolver{broken, good}, WithEjection(100, time.Minute))
+	for i := 0; i < 4; i++ {
+		if by := answeredBy(t, m); by != "good." {
+			t.Fatalf("expected good., but got %v", by)
+		}
+	}
+	// すぐ失敗するものは速いとみなさない。
+	if broken.Calls() != 1 {
+		t.Fatalf("failing upstream should be sorted last, but called %v times", broken.Calls())
+	}
+}
+
+func TestRace(t *testing.T) {
+	slow := fake("slow.", 200*time.Millisecond, nil)
+	fast := fake("fast.", time.Millisecond, nil)
+	broken := fake("broken.", 0, errors.New("bie"))
+	m := New(Race, []resolver.Resolver{slow, broken, fast})
+	if by := answeredBy(t, m); by != "fast." {
+		t.Fatalf("expected fast., but got %v", by)
+	}
+}
@@ -0,0 +1,56 @@
+package netresolver
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+)
+
+// New makes net.Resolver which resolves through r
+func New(r resolver.Resolver) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial:     Dial(r),
+	}
+}
+
+// Dial returns Dial for net.Resolver, the conn talks to r in process regardless of network and address
+func Dial(r resolver.Resolver) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		// PacketConn でない conn には TCP と同じく長さ付きで問い合わせてくる。
+		client, server := net.Pipe()
+		go serve(r, server)
+		return client, nil
+	}
+}
+
+func serve(r resolver.Resolver, conn net.Conn) {
+	defer conn.Close()
+	for {
+		var l [2]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return
+		}
+		msg := make([]byte, binary.BigEndian.Uint16(l[:]))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		q, err := dns.ParseQuery(msg)
+		if err != nil {
+			return
+		}
+		ans := resolver.Forward(r, q)
+		b, err := ans.Pack()
+		if err != nil {
+			return
+		}
+		binary.BigEndian.PutUint16(l[:], uint16(len(b)))
+		if _, err := conn.Write(append(l[:], b...)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package netresolver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver/resolvertest"
+)
+
+func TestLookup(t *testing.T) {
+	z := resolvertest.New(resolvertest.Zone(map[string][]dns.ResourceRecord{
+		"app.example.": {
+			dns.NewResourceRecord("app.example.", dns.A, dns.IN, 300, []byte{192, 0, 2, 1}),
+			dns.NewResourceRecord("app.example.", dns.AAAA, dns.IN, 300, net.ParseIP("2001:db8::1")),
+		},
+		"www.example.": {
+			dns.NewResourceRecord("www.example.", dns.CNAME, dns.IN, 300, dns.PackName("app.example.")),
+			dns.NewResourceRecord("app.example.", dns.A, dns.IN, 300, []byte{192, 0, 2, 1}),
+		},
+		"1.2.0.192.in-addr.arpa.": {
+			dns.NewResourceRecord("1.2.0.192.in-addr.arpa.", dns.PTR, dns.IN, 300, dns.PackName("app.example.")),
+		},
+	}))
+	r := New(z)
+	ctx := context.Background()
+
+	addrs, err := r.LookupHost(ctx, "app.example.")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("unexpected addrs: %v", addrs)
+	}
+	cname, err := r.LookupCNAME(ctx, "www.example.")
+	if err != nil || cname != "app.example." {
+		t.Fatalf("unexpected cname: %v, %v", cname, err)
+	}
+	names, err := r.LookupAddr(ctx, "192.0.2.1")
+	if err != nil || len(names) != 1 || names[0] != "app.example." {
+		t.Fatalf("unexpected names: %v, %v", names, err)
+	}
+	if _, err := r.LookupHost(ctx, "nx.example."); err == nil {
+		t.Fatal("err should not be nil")
+	} else if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+		t.Fatalf("should be not found: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package resolvconf
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/implements"
+	"github.com/nna774/zorori/resolver/search"
+	"github.com/nna774/zorori/resolver/udp"
+)
+
+// see resolv.conf(5)
+const (
+	maxNameservers  = 3
+	defaultNdots    = 1
+	defaultTimeout  = 5 * time.Second
+	defaultAttempts = 2
+	maxNdots        = 15
+	maxTimeout      = 30 * time.Second
+	maxAttempts     = 5
+	edns0UDPSize    = 1232
+)
+
+// Config is resolv.conf
+type Config struct {
+	Nameservers []net.IP
+	Search      []string
+	Ndots       int
+	Timeout     time.Duration
+	Attempts    int
+	Rotate      bool
+	EDNS0       bool
+}
+
+// Load reads resolv.conf at path
+func Load(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads resolv.conf
+func Parse(r io.Reader) (Config, error) {
+	c := Config{
+		Ndots:    defaultNdots,
+		Timeout:  defaultTimeout,
+		Attempts: defaultAttempts,
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			// fe80::1%eth0 のような zone は落とす。
+			addr := strings.SplitN(fields[1], "%", 2)[0]
+			if ip := net.ParseIP(addr); ip != nil && len(c.Nameservers) < maxNameservers {
+				c.Nameservers = append(c.Nameservers, ip)
+			}
+		case "domain":
+			c.Search = []string{dns.Fqdn(fields[1])}
+		case "search":
+			c.Search = []string{}
+			for _, s := range fields[1:] {
+				c.Search = append(c.Search, dns.Fqdn(s))
+			}
+		case "options":
+			for _, opt := range fields[1:] {
+				c.option(opt)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Config{}, err
+	}
+	if len(c.Nameservers) == 0 {
+		c.Nameservers = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	return c, nil
+}
+
+func (c *Config) option(opt string) {
+	kv := strings.SplitN(opt, ":", 2)
+	n := 0
+	if len(kv) == 2 {
+		var err error
+		if n, err = strconv.Atoi(kv[1]); err != nil || n < 0 {
+			return
+		}
+	}
+	switch kv[0] {
+	case "ndots":
+		if n > maxNdots {
+			n = maxNdots
+		}
+		c.Ndots = n
+	case "timeout":
+		c.Timeout = time.Duration(n) * time.Second
+		if c.Timeout > maxTimeout {
+			c.Timeout = maxTimeout
+		}
+		if c.Timeout == 0 {
+			c.Timeout = time.Second
+		}
+	case "attempts":
+		if n > maxAttempts {
+			n = maxAttempts
+		}
+		if n == 0 {
+			n = 1
+		}
+		c.Attempts = n
+	case "rotate":
+		c.Rotate = true
+	case "edns0":
+		c.EDNS0 = true
+	}
+}
+
+// Stub makes udp stub resolver of c
+func (c *Config) Stub() resolver.Resolver {
+	opts := []udp.Option{
+		udp.WithTimeout(c.Timeout),
+		udp.WithAttempts(c.Attempts),
+	}
+	if c.Rotate {
+		opts = append(opts, udp.WithRotate())
+	}
+	if c.EDNS0 {
+		opts = append(opts, udp.WithEDNS0(edns0UDPSize))
+	}
+	return udp.NewUDPStubResolverWithServers(c.Nameservers, opts...)
+}
+
+type confResolver struct {
+	implements.Methods
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	conf    Config
+	stub    resolver.Resolver
+}
+
+// NewResolver makes stub resolver from resolv.conf at path, which expands names by search list.
+// The file is reloaded when it changes
+func NewResolver(path string) (resolver.Resolver, error) {
+	r := &confResolver{path: path}
+	r.Methods = implements.Methods{Resolve: r.Resolve}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload reads the file again if it has changed.
+func (r *confResolver) reload() error {
+	st, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stub != nil && st.ModTime().Equal(r.modTime) {
+		return nil
+	}
+	conf, err := Load(r.path)
+	if err != nil {
+		return err
+	}
+	r.modTime = st.ModTime()
+	r.conf = conf
+	r.stub = search.New(conf.Stub(), conf.Search, conf.Ndots)
+	return nil
+}
+
+func (r *confResolver) current() resolver.Resolver {
+	// 読めなくなったら前の設定を使い続ける。
+	r.reload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stub
+}
+
+// Resolve asks name of t to the nameservers with search list
+func (r *confResolver) Resolve(name string, t dns.QueryType) (dns.Answer, error) {
+	return r.current().Resolve(name, t)
+}
@@ -0,0 +1,87 @@
+package resolvconf
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load("testdata/resolv.conf")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	expected := []net.IP{net.ParseIP("192.0.2.53"), net.ParseIP("2001:db8::53"), net.ParseIP("198.51.100.53")}
+	if len(c.Nameservers) != len(expected) {
+		t.Fatalf("expected: %v, but got %v", expected, c.Nameservers)
+	}
+	for i := range expected {
+		if !expected[i].Equal(c.Nameservers[i]) {
+			t.Fatalf("expected: %v, but got %v", expected, c.Nameservers)
+		}
+	}
+	if len(c.Search) != 2 || c.Search[0] != "corp.example." || c.Search[1] != "example.com." {
+		t.Fatalf("unexpected search: %v", c.Search)
+	}
+	if c.Ndots != 2 || c.Timeout != 3*time.Second || c.Attempts != maxAttempts || !c.Rotate || !c.EDNS0 {
+		t.Fatalf("unexpected options: %+v", c)
+	}
+}
+
+func TestParseDefault(t *testing.T) {
+	c, err := Parse(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if len(c.Nameservers) != 1 || !c.Nameservers[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("unexpected nameservers: %v", c.Nameservers)
+	}
+	if c.Ndots != defaultNdots || c.Timeout != defaultTimeout || c.Attempts != defaultAttempts {
+		t.Fatalf("unexpected options: %+v", c)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolvconf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolv.conf")
+	if err := ioutil.WriteFile(path, []byte("nameserver 192.0.2.53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewResolver(path)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	r := res.(*confResolver)
+	nameserver := func() net.IP {
+		r.current()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.conf.Nameservers[0]
+	}
+	if ns := nameserver(); !ns.Equal(net.ParseIP("192.0.2.53")) {
+		t.Fatalf("unexpected nameserver: %v", ns)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("nameserver 192.0.2.54\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if ns := nameserver(); !ns.Equal(net.ParseIP("192.0.2.54")) {
+		t.Fatalf("should be reloaded: %v", ns)
+	}
+
+	// 消えたら前の設定を使い続ける。
+	os.Remove(path)
+	if ns := nameserver(); !ns.Equal(net.ParseIP("192.0.2.54")) {
+		t.Fatalf("previous config should be kept: %v", ns)
+	}
+}
@@ -1,9 +1,17 @@
 package resolver
 
-import "github.com/nna774/zorori/dns"
+import (
+	"net"
+
+	"github.com/nna774/zorori/dns"
+)
 
 // Resolver is the interface of DNS resolver
 type Resolver interface {
+	Resolve(string, dns.QueryType) (dns.Answer, error)
 	AResolve(string) (dns.AResult, error)
-	SVCBResolve() (dns.SVCBResult, error)
+	AAAAResolve(string) (dns.AAAAResult, error)
+	SVCBResolve(string) ([]dns.SVCBResult, error)
+	HTTPSResolve(string) ([]dns.SVCBResult, error)
+	ReverseResolve(net.IP) (dns.PTRResult, error)
 }
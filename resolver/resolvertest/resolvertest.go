@@ -0,0 +1,80 @@
+// Package resolvertest provides a fake resolver for tests
+package resolvertest
+
+import (
+	"sync"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver/implements"
+)
+
+// Resolver is a fake resolver answering by a func, it records asked names
+type Resolver struct {
+	implements.Methods
+	resolve implements.ResolveFunc
+
+	mu    sync.Mutex
+	asked []string
+}
+
+// New is ctor of Resolver
+func New(resolve implements.ResolveFunc) *Resolver {
+	r := &Resolver{resolve: resolve}
+	r.Methods = implements.Methods{Resolve: r.Resolve}
+	return r
+}
+
+// Resolve records name and answers by the func
+func (r *Resolver) Resolve(name string, t dns.QueryType) (dns.Answer, error) {
+	r.mu.Lock()
+	r.asked = append(r.asked, name)
+	r.mu.Unlock()
+	return r.resolve(name, t)
+}
+
+// Asked returns names asked so far
+func (r *Resolver) Asked() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.asked...)
+}
+
+// Calls returns how many times Resolve is called
+func (r *Resolver) Calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.asked)
+}
+
+// Reset forgets asked names
+func (r *Resolver) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.asked = nil
+}
+
+// Zone answers records of the type and CNAME from rrs, or NXDOMAIN if the name is absent
+func Zone(rrs map[string][]dns.ResourceRecord) implements.ResolveFunc {
+	return func(name string, t dns.QueryType) (dns.Answer, error) {
+		ans := dns.NewAnswer(dns.NewQuestion(name, t))
+		records, ok := rrs[dns.Normalize(name)]
+		if !ok {
+			ans.Header.SetRCode(dns.NXDomain)
+			return ans, nil
+		}
+		for _, rr := range records {
+			if rr.T == t || rr.T == dns.CNAME {
+				ans.Answers = append(ans.Answers, rr)
+			}
+		}
+		return ans, nil
+	}
+}
+
+// CNAME answers CNAME to target for any name, it tells which resolver answered
+func CNAME(target string) implements.ResolveFunc {
+	return func(name string, t dns.QueryType) (dns.Answer, error) {
+		rr := dns.NewResourceRecord(name, dns.CNAME, dns.IN, 300, dns.PackName(target))
+		return dns.Answer{Answers: []dns.ResourceRecord{rr}}, nil
+	}
+}
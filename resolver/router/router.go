@@ -0,0 +1,164 @@
+package router
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/doh"
+	"github.com/nna774/zorori/resolver/dot"
+	"github.com/nna774/zorori/resolver/implements"
+	"github.com/nna774/zorori/resolver/multi"
+	"github.com/nna774/zorori/resolver/udp"
+	"github.com/pkg/errors"
+)
+
+type route struct {
+	suffix   string
+	labels   int
+	resolver resolver.Resolver
+}
+
+type router struct {
+	implements.Methods
+	routes []route
+}
+
+var errNoRoute = errors.New("no route")
+
+// New makes resolver which routes queries to the resolver of the longest matching suffix.
+// "." matches everything
+func New(routes map[string]resolver.Resolver) resolver.Resolver {
+	r := &router{}
+	r.Methods = implements.Methods{Resolve: r.Resolve}
+	for suffix, res := range routes {
+		r.routes = append(r.routes, route{
+			suffix:   dns.Fqdn(suffix),
+			labels:   len(dns.SplitLabels(suffix)),
+			resolver: res,
+		})
+	}
+	return r
+}
+
+// LoadFile makes router from config file
+func LoadFile(path string) (resolver.Resolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse makes router from config, each line is a suffix and comma separated upstreams like
+//
+//	corp.example.  udp:10.0.0.53,udp:10.0.0.54
+//	.              doh:https://dns.google/dns-query
+func Parse(r io.Reader) (resolver.Resolver, error) {
+	routes := map[string]resolver.Resolver{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, errors.Errorf("line %d: expected suffix and upstreams", line)
+		}
+		suffix := dns.Normalize(fields[0])
+		if _, dup := routes[suffix]; dup {
+			return nil, errors.Errorf("line %d: duplicated suffix %v", line, suffix)
+		}
+		upstreams := []resolver.Resolver{}
+		for _, spec := range strings.Split(fields[1], ",") {
+			u, err := ParseUpstream(spec)
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", line)
+			}
+			upstreams = append(upstreams, u)
+		}
+		if len(upstreams) == 1 {
+			routes[suffix] = upstreams[0]
+		} else {
+			routes[suffix] = multi.New(multi.Failover, upstreams)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return New(routes), nil
+}
+
+// ParseUpstream makes resolver from spec, one of udp:IP, doh:URL, dot:HOST:PORT[@SERVERNAME] or full
+func ParseUpstream(spec string) (resolver.Resolver, error) {
+	if spec == "full" {
+		return udp.NewUDPFullResolver(), nil
+	}
+	i := strings.IndexByte(spec, ':')
+	if i < 0 {
+		return nil, errors.Errorf("bad upstream: %v", spec)
+	}
+	kind, arg := spec[:i], spec[i+1:]
+	switch kind {
+	case "udp":
+		ip := net.ParseIP(arg)
+		if ip == nil {
+			return nil, errors.Errorf("bad ip addr: %v", arg)
+		}
+		return udp.NewUDPStubResolver(ip), nil
+	case "doh":
+		return doh.NewDoHResolver(arg), nil
+	case "dot":
+		addr, serverName := arg, ""
+		if j := strings.IndexByte(arg, '@'); j >= 0 {
+			addr, serverName = arg[:j], arg[j+1:]
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if serverName == "" {
+			serverName = host
+		}
+		return dot.NewDoTResolver(addr, serverName), nil
+	default:
+		return nil, errors.Errorf("unknown upstream: %v", kind)
+	}
+}
+
+// match returns the resolver of the longest matching suffix.
+func (r *router) match(name string) (resolver.Resolver, error) {
+	var best *route
+	for i := range r.routes {
+		if !dns.IsSubDomain(name, r.routes[i].suffix) {
+			continue
+		}
+		if best == nil || r.routes[i].labels > best.labels {
+			best = &r.routes[i]
+		}
+	}
+	if best == nil {
+		return nil, errNoRoute
+	}
+	return best.resolver, nil
+}
+
+// Resolve asks name of t to the routed resolver
+func (r *router) Resolve(name string, t dns.QueryType) (dns.Answer, error) {
+	res, err := r.match(name)
+	if err != nil {
+		return dns.Answer{}, err
+	}
+	return res.Resolve(name, t)
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/resolvertest"
+)
+
+func TestRoute(t *testing.T) {
+	r := New(map[string]resolver.Resolver{
+		".":                 resolvertest.New(resolvertest.CNAME("default.")),
+		"corp.example.":     resolvertest.New(resolvertest.CNAME("corp.")),
+		"dev.corp.example":  resolvertest.New(resolvertest.CNAME("dev.")),
+		"notcorp.example.":  resolvertest.New(resolvertest.CNAME("notcorp.")),
+		"example.internal.": resolvertest.New(resolvertest.CNAME("internal.")),
+	})
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"www.example.com", "default."},
+		{"corp.example", "corp."},
+		{"db.CORP.example.", "corp."},
+		{"db.dev.corp.example.", "dev."},
+		{"xcorp.example.", "default."},
+		{"a.notcorp.example.", "notcorp."},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ans, err := r.Resolve(c.name, dns.A)
+			if err != nil {
+				t.Fatalf("err should be nil: %v", err)
+			}
+			by, _ := ans.Answers[0].CNAMETO()
+			if by != c.expected {
+				t.Fatalf("expected: %v, but got %v", c.expected, by)
+			}
+		})
+	}
+}
+
+func TestNoRoute(t *testing.T) {
+	r := New(map[string]resolver.Resolver{"corp.example.": resolvertest.New(resolvertest.CNAME("corp."))})
+	if _, err := r.Resolve("www.example.com.", dns.A); err != errNoRoute {
+		t.Fatalf("expected: %v, but got %v", errNoRoute, err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	config := `
+# internal
+corp.example.  udp:10.0.0.53,udp:10.0.0.54
+.              doh:https://dns.google/dns-query # default
+`
+	if _, err := Parse(strings.NewReader(config)); err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	invalids := []string{
+		"corp.example.",
+		"corp.example. udp:bie",
+		"corp.example. tcp:10.0.0.53",
+		"corp.example. udp:10.0.0.53\ncorp.example udp:10.0.0.54",
+	}
+	for _, config := range invalids {
+		if _, err := Parse(strings.NewReader(config)); err == nil {
+			t.Fatalf("err should not be nil: %q", config)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package search
+
+import (
+	"net"
+	"strings"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver"
+	"github.com/nna774/zorori/resolver/implements"
+	"github.com/pkg/errors"
+)
+
+// Resolver expands names by search list before asking next. see resolv.conf(5)
+type Resolver struct {
+	next   resolver.Resolver
+	search []string
+	ndots  int
+}
+
+var errNoCandidates = errors.New("no candidates")
+
+// New makes resolver which expands names by search list with ndots
+func New(next resolver.Resolver, search []string, ndots int) *Resolver {
+	s := make([]string, 0, len(search))
+	for _, domain := range search {
+		domain = dns.Fqdn(domain)
+		if domain == "." {
+			// ルートを付けたものは絶対名と同じなので候補にしない。
+			continue
+		}
+		s = append(s, domain)
+	}
+	return &Resolver{next: next, search: s, ndots: ndots}
+}
+
+// Candidates returns names to be asked in order
+func (r *Resolver) Candidates(name string) []string {
+	if strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+	expanded := make([]string, 0, len(r.search)+1)
+	for _, domain := range r.search {
+		expanded = append(expanded, name+"."+domain)
+	}
+	absolute := name + "."
+	if strings.Count(name, ".") >= r.ndots {
+		return append([]string{absolute}, expanded...)
+	}
+	return append(expanded, absolute)
+}
+
+// ResolveName asks candidates of name in order, and returns the answer and the candidate which has records of t
+func (r *Resolver) ResolveName(name string, t dns.QueryType) (dns.Answer, string, error) {
+	var ans dns.Answer
+	var lastErr error = errNoCandidates
+	candidate := ""
+	for _, c := range r.Candidates(name) {
+		res, err := r.next.Resolve(c, t)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		_, rrs, err := dns.Chase(res.Answers, c, t)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(rrs) > 0 {
+			return res, c, nil
+		}
+		// NXDOMAIN や NODATA なら次の候補へ。
+		ans, candidate, lastErr = res, c, nil
+	}
+	return ans, candidate, lastErr
+}
+
+// Resolve asks name of t with search list
+func (r *Resolver) Resolve(name string, t dns.QueryType) (dns.Answer, error) {
+	ans, _, err := r.ResolveName(name, t)
+	return ans, err
+}
+
+// AResolve resolves A
+func (r *Resolver) AResolve(domain string) (dns.AResult, error) {
+	ans, c, err := r.ResolveName(domain, dns.A)
+	if err != nil {
+		return implements.AFail(err)
+	}
+	return implements.AResultOf(ans, c)
+}
+
+// AAAAResolve resolves AAAA
+func (r *Resolver) AAAAResolve(domain string) (dns.AAAAResult, error) {
+	ans, c, err := r.ResolveName(domain, dns.AAAA)
+	if err != nil {
+		return implements.AAAAFail(err)
+	}
+	return implements.AAAAResultOf(ans, c)
+}
+
+// SVCBResolve resolves SVCB
+func (r *Resolver) SVCBResolve(name string) ([]dns.SVCBResult, error) {
+	return r.svcb(name, dns.SVCB)
+}
+
+// HTTPSResolve resolves HTTPS
+func (r *Resolver) HTTPSResolve(name string) ([]dns.SVCBResult, error) {
+	return r.svcb(name, dns.HTTPS)
+}
+
+// ReverseResolve resolves PTR of ip
+func (r *Resolver) ReverseResolve(ip net.IP) (dns.PTRResult, error) {
+	return implements.ResolvePTR(r.Resolve, ip)
+}
+
+func (r *Resolver) svcb(name string, t dns.QueryType) ([]dns.SVCBResult, error) {
+	ans, c, err := r.ResolveName(name, t)
+	if err != nil {
+		return implements.SVCBFail(err)
+	}
+	// 最初の問い合わせは済んでいるので使い回す。
+	first := true
+	return implements.ResolveSVCB(func(n string, qt dns.QueryType) (dns.Answer, error) {
+		if first && n == c && qt == t {
+			first = false
+			return ans, nil
+		}
+		return r.next.Resolve(n, qt)
+	}, c, t)
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/nna774/zorori/resolver/resolvertest"
+)
+
+func TestCandidates(t *testing.T) {
+	r := New(nil, []string{"corp.example", "example.com."}, 2)
+	cases := []struct {
+		name     string
+		expected []string
+	}{
+		{"db", []string{"db.corp.example.", "db.example.com.", "db."}},
+		{"db.eu", []string{"db.eu.corp.example.", "db.eu.example.com.", "db.eu."}},
+		{"db.eu.west", []string{"db.eu.west.", "db.eu.west.corp.example.", "db.eu.west.example.com."}},
+		{"db.", []string{"db."}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := r.Candidates(c.name)
+			if strings.Join(got, " ") != strings.Join(c.expected, " ") {
+				t.Fatalf("expected: %v, but got %v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestCandidatesRoot(t *testing.T) {
+	r := New(nil, []string{".", "example.com", ""}, 1)
+	got := r.Candidates("db")
+	if strings.Join(got, " ") != "db.example.com. db." {
+		t.Fatalf("root should not be a search domain: %v", got)
+	}
+}
+
+func TestResolveName(t *testing.T) {
+	z := resolvertest.New(resolvertest.Zone(map[string][]dns.ResourceRecord{
+		"db.example.com.":  {dns.NewResourceRecord("db.example.com.", dns.A, dns.IN, 300, []byte{192, 0, 2, 1})},
+		"www.example.org.": {dns.NewResourceRecord("www.example.org.", dns.A, dns.IN, 300, []byte{192, 0, 2, 1})},
+	}))
+	r := New(z, []string{"corp.example", "example.com"}, 1)
+
+	ans, c, err := r.ResolveName("db", dns.A)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if c != "db.example.com." || len(ans.Answers) != 1 {
+		t.Fatalf("expected db.example.com., but got %v", c)
+	}
+
+	z.Reset()
+	a, err := r.AResolve("www.example.org")
+	if err != nil || a.IP() == nil {
+		t.Fatalf("should be resolved: %v %v", a.IPs(), err)
+	}
+	if len(z.Asked()) != 1 {
+		t.Fatalf("absolute name should be asked first: %v", z.Asked())
+	}
+
+	ans, _, err = r.ResolveName("nothing", dns.A)
+	if err != nil || ans.Header.RCode() != dns.NXDomain {
+		t.Fatalf("expected NXDOMAIN, but got %v %v", ans.Header, err)
+	}
+}
@@ -0,0 +1,212 @@
+package udp
+
+import (
+	"net"
+	"strings"
+
+	"github.com/nna774/zorori/dns"
+	"github.com/pkg/errors"
+)
+
+// RFC 9156 の推奨値
+const (
+	maxMinimiseCount = 10
+	minimiseOneLab   = 4
+)
+
+const (
+	maxReferrals = 30
+	// NS の名前解決や CNAME を追う深さ
+	maxDepth = 8
+)
+
+var (
+	errTooManyReferrals = errors.New("too many referrals")
+	errTooDeep          = errors.New("too deep recursion")
+	errNoServers        = errors.New("no reachable name servers")
+)
+
+// DisableQNAMEMinimisation makes full resolver send full query name to every server
+func DisableQNAMEMinimisation() Option {
+	return func(t *udpResolver) {
+		t.qmin = false
+	}
+}
+
+// Resolve asks name of qt, iterating from root if it is full resolver
+func (t *udpResolver) Resolve(name string, qt dns.QueryType) (dns.Answer, error) {
+	if t.stub {
+		return t.stubResolve(name, qt)
+	}
+	return t.fullResolve(name, qt, 0)
+}
+
+// fullResolve iterates from root, and follows CNAME which goes out of the answer.
+func (t *udpResolver) fullResolve(name string, qt dns.QueryType, depth int) (dns.Answer, error) {
+	ans, err := t.iterate(name, qt, depth)
+	if err != nil {
+		return ans, err
+	}
+	searching := name
+	for i := 0; i < maxDepth; i++ {
+		target, rrs, err := dns.Chase(ans.Answers, searching, qt)
+		if err != nil {
+			return ans, err
+		}
+		if len(rrs) > 0 || dns.Same(target, searching) {
+			return ans, nil
+		}
+		next, err := t.iterate(target, qt, depth)
+		if err != nil {
+			return ans, err
+		}
+		ans.Header = next.Header
+		ans.Answers = append(ans.Answers, next.Answers...)
+		searching = target
+	}
+	return ans, nil
+}
+
+// iterate walks from root to the zone of name. see RFC 9156 for the minimisation.
+func (t *udpResolver) iterate(name string, qt dns.QueryType, depth int) (dns.Answer, error) {
+	if depth > maxDepth {
+		return dns.Answer{}, errTooDeep
+	}
+	labels := dns.SplitLabels(name)
+	servers := t.servers
+	zone := "."
+	minimise := t.qmin
+	cur := 0 // 今聞いている名前のラベル数
+	count := 0
+	for i := 0; i < maxReferrals; i++ {
+		qname, qtype := name, qt
+		minimised := false
+		if minimise && cur < len(labels) {
+			cur += minimiseStep(len(labels)-cur, count)
+			count++
+			if cur < len(labels) {
+				qname = strings.Join(labels[len(labels)-cur:], ".") + "."
+				qtype = dns.A
+				minimised = true
+			}
+		}
+		ans, err := t.ask(servers, qname, qtype)
+		if err != nil {
+			if minimised {
+				// 壊れたサーバーかもしれないので全部送って聞き直す。
+				minimise = false
+				continue
+			}
+			return ans, err
+		}
+		if cut, ok := referral(ans, zone, qname); ok {
+			ns := t.nameServers(ans, zone, cut, depth)
+			if len(ns) == 0 {
+				return ans, errNoServers
+			}
+			servers, zone = ns, cut
+			cur = len(dns.SplitLabels(cut))
+			continue
+		}
+		if !minimised {
+			return ans, nil
+		}
+		if ans.Header.RCode() != dns.NoError {
+			// NXDOMAIN を含め、空ノードを正しく扱えないサーバーがいるので relaxed に全部送る。
+			minimise = false
+		}
+		// NoError ならゾーンカットではなかったので、次のラベルへ。
+	}
+	return dns.Answer{}, errTooManyReferrals
+}
+
+// minimiseStep returns how many labels to add. see RFC 9156 section 3.
+func minimiseStep(remaining, count int) int {
+	if count < minimiseOneLab {
+		return 1
+	}
+	left := maxMinimiseCount - count
+	if left <= 1 {
+		return remaining
+	}
+	return (remaining + left - 1) / left
+}
+
+// ask tries servers in order.
+func (t *udpResolver) ask(servers []net.IP, name string, qt dns.QueryType) (dns.Answer, error) {
+	var ans dns.Answer
+	err := errNoServers
+	for _, s := range servers {
+		ans, err = t.exchange(s, name, qt)
+		if err != nil {
+			continue
+		}
+		rcode := ans.Header.RCode()
+		if rcode == dns.ServFail || rcode == dns.Refused {
+			err = errors.Errorf("%v answered rcode %v", s, rcode)
+			continue
+		}
+		return ans, nil
+	}
+	return ans, err
+}
+
+// referral returns zone cut if ans is a referral from zone.
+func referral(ans dns.Answer, zone, qname string) (string, bool) {
+	if ans.Header.RCode() != dns.NoError || len(ans.Answers) > 0 {
+		return "", false
+	}
+	for _, rr := range ans.Authorities {
+		if rr.T != dns.NS {
+			continue
+		}
+		if dns.IsSubDomain(rr.Name, zone) && !dns.Same(rr.Name, zone) && dns.IsSubDomain(qname, rr.Name) {
+			return rr.Name, true
+		}
+	}
+	return "", false
+}
+
+// nameServers returns addrs of name servers for cut, from glue or resolving them.
+func (t *udpResolver) nameServers(ans dns.Answer, zone, cut string, depth int) []net.IP {
+	names := []string{}
+	for _, rr := range ans.Authorities {
+		if rr.T == dns.NS && dns.Same(rr.Name, cut) {
+			n, _ := rr.NSName()
+			names = append(names, n)
+		}
+	}
+	ips := []net.IP{}
+	for _, rr := range ans.Additionals {
+		// 親ゾーンの外の glue は信用しない。
+		if rr.T != dns.A || !dns.IsSubDomain(rr.Name, zone) {
+			continue
+		}
+		for _, n := range names {
+			if dns.Same(rr.Name, n) {
+				ip, _ := rr.IP()
+				ips = append(ips, ip)
+			}
+		}
+	}
+	if len(ips) > 0 {
+		return ips
+	}
+	// glue が無いので NS の名前を引く。
+	for _, n := range names {
+		a, err := t.fullResolve(n, dns.A, depth+1)
+		if err != nil {
+			continue
+		}
+		for _, rr := range a.Answers {
+			if rr.T == dns.A {
+				ip, _ := rr.IP()
+				ips = append(ips, ip)
+			}
+		}
+		if len(ips) > 0 {
+			break
+		}
+	}
+	return ips
+}
@@ -0,0 +1,248 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+)
+
+func TestMinimiseStep(t *testing.T) {
+	// 20 ラベルの名前でも MAX_MINIMISE_COUNT 回で全部送り終える。
+	labels := 20
+	cur := 0
+	count := 0
+	for cur < labels {
+		cur += minimiseStep(labels-cur, count)
+		count++
+		if count <= minimiseOneLab && cur != count {
+			t.Fatalf("first %v steps should add one label, but got %v at %v", minimiseOneLab, cur, count)
+		}
+	}
+	if cur != labels {
+		t.Fatalf("expected %v labels, but got %v", labels, cur)
+	}
+	if count > maxMinimiseCount {
+		t.Fatalf("too many steps: %v", count)
+	}
+}
+
+// fakeZone answers like an authoritative server from rrs, NS below origin are zone cuts.
+type fakeZone struct {
+	origin string
+	rrs    []fakeRR
+}
+
+func (z fakeZone) lookup(name string, qt dns.QueryType) (int, []fakeRR, []fakeRR, []fakeRR) {
+	for _, rr := range z.rrs {
+		if rr.t != dns.NS || dns.Same(rr.name, z.origin) || !dns.IsSubDomain(name, rr.name) {
+			continue
+		}
+		// ゾーンカットの下なので referral を返す。
+		authorities, additionals := []fakeRR{}, []fakeRR{}
+		for _, ns := range z.rrs {
+			if ns.t == dns.NS && dns.Same(ns.name, rr.name) {
+				authorities = append(authorities, ns)
+				for _, glue := range z.rrs {
+					if glue.t == dns.A && dns.Same(glue.name, ns.target) {
+						additionals = append(additionals, glue)
+					}
+				}
+			}
+		}
+		return dns.NoError, nil, authorities, additionals
+	}
+	exists := false
+	answers := []fakeRR{}
+	for _, rr := range z.rrs {
+		if dns.IsSubDomain(rr.name, name) {
+			// 下に名前があれば空ノードでも存在する。
+			exists = true
+		}
+		if dns.Same(rr.name, name) && rr.t == qt {
+			answers = append(answers, rr)
+		}
+	}
+	if !exists {
+		return dns.NXDomain, nil, nil, nil
+	}
+	return dns.NoError, answers, nil, nil
+}
+
+// fakeServer answers a question.
+type fakeServer func(name string, qt dns.QueryType) (int, []fakeRR, []fakeRR, []fakeRR)
+
+var testZones = map[string]fakeZone{
+	"root": {".", []fakeRR{
+		fakeNS("example.", "ns.example."), fakeA("ns.example.", "198.51.100.2"),
+		fakeNS("other.", "ns.other."), fakeA("ns.other.", "198.51.100.3"),
+		fakeNS("deep.", "ns.deep."), fakeA("ns.deep.", "198.51.100.5"),
+	}},
+	"198.51.100.2": {"example.", []fakeRR{
+		fakeNS("example.", "ns.example."), fakeA("ns.example.", "198.51.100.2"),
+		fakeA("www.example.", "192.0.2.1"),
+		fakeA("www.deep.ent.example.", "192.0.2.2"),
+		fakeA("www.deep.refused.example.", "192.0.2.3"),
+		// glue の無い委任
+		fakeNS("sub.example.", "ns.other."),
+	}},
+	"198.51.100.3": {"other.", []fakeRR{
+		fakeNS("other.", "ns.other."), fakeA("ns.other.", "198.51.100.4"),
+	}},
+	"198.51.100.4": {"sub.example.", []fakeRR{
+		fakeNS("sub.example.", "ns.other."),
+		fakeA("www.sub.example.", "192.0.2.4"),
+	}},
+}
+
+// brokenExample answers NXDOMAIN for an empty non-terminal, and REFUSED for a name.
+type brokenExample struct {
+	mu sync.Mutex
+	// asked は受けた問い合わせの名前。
+	asked []string
+}
+
+func (b *brokenExample) serve(name string, qt dns.QueryType) (int, []fakeRR, []fakeRR, []fakeRR) {
+	b.mu.Lock()
+	b.asked = append(b.asked, dns.Normalize(name))
+	b.mu.Unlock()
+	switch {
+	case dns.Same(name, "ent.example."):
+		return dns.NXDomain, nil, nil, nil
+	case dns.Same(name, "refused.example."):
+		return dns.Refused, nil, nil, nil
+	}
+	return testZones["198.51.100.2"].lookup(name, qt)
+}
+
+// askedAfter returns the name asked just after name.
+func (b *brokenExample) askedAfter(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, n := range b.asked[:len(b.asked)-1] {
+		if n == name {
+			return b.asked[i+1]
+		}
+	}
+	return ""
+}
+
+// deepReferrals refers one label deeper on every query.
+func deepReferrals() fakeServer {
+	var count int32
+	return func(name string, qt dns.QueryType) (int, []fakeRR, []fakeRR, []fakeRR) {
+		labels := dns.SplitLabels(name)
+		n := int(atomic.AddInt32(&count, 1)) + 1
+		if n > len(labels) {
+			n = len(labels)
+		}
+		cut := strings.Join(labels[len(labels)-n:], ".") + "."
+		return dns.NoError, nil, []fakeRR{fakeNS(cut, "ns."+cut)}, []fakeRR{fakeA("ns."+cut, "198.51.100.5")}
+	}
+}
+
+// startAuthorities runs fake servers on loopback, and returns them with addrs by the IP in the zones.
+func startAuthorities(t *testing.T) ([]net.PacketConn, *brokenExample, map[string]string) {
+	broken := &brokenExample{}
+	handlers := map[string]fakeServer{
+		"198.51.100.2": broken.serve,
+		"198.51.100.5": deepReferrals(),
+	}
+	for ip, z := range testZones {
+		if _, ok := handlers[ip]; !ok {
+			handlers[ip] = z.lookup
+		}
+	}
+	pcs := []net.PacketConn{}
+	addrs := map[string]string{}
+	for ip, h := range handlers {
+		h := h
+		pc := listenUDP(t, func(q []byte) [][]byte {
+			name, qt, _ := parseQuestion(q)
+			rcode, answers, authorities, additionals := h(name, qt)
+			return [][]byte{packReply(q, rcode, answers, authorities, additionals)}
+		})
+		pcs = append(pcs, pc)
+		addrs[ip] = pc.LocalAddr().String()
+	}
+	return pcs, broken, addrs
+}
+
+func testFull(addrs map[string]string, opts ...Option) *udpResolver {
+	r := NewUDPFullResolver(opts...).(*udpResolver)
+	r.timeout = time.Second
+	r.addr = func(ip net.IP) string {
+		if addr, ok := addrs[ip.String()]; ok {
+			return addr
+		}
+		// 本物のルートサーバーの代わり
+		return addrs["root"]
+	}
+	return r
+}
+
+func TestIterate(t *testing.T) {
+	pcs, broken, addrs := startAuthorities(t)
+	defer func() {
+		for _, pc := range pcs {
+			pc.Close()
+		}
+	}()
+
+	cases := []struct {
+		name     string
+		qmin     bool
+		expected string
+		// relaxed は全部送り直すきっかけになる名前。
+		relaxed string
+	}{
+		{"www.example.", true, "192.0.2.1", ""},
+		{"www.example.", false, "192.0.2.1", ""},
+		// 空ノードに NXDOMAIN を返すサーバーやエラーを返すサーバーには全部送り直す。
+		{"www.deep.ent.example.", true, "192.0.2.2", "ent.example."},
+		{"www.deep.refused.example.", true, "192.0.2.3", "refused.example."},
+		// glue が無いので ns.other. を引いてから聞く。
+		{"www.sub.example.", true, "192.0.2.4", ""},
+		{"www.sub.example.", false, "192.0.2.4", ""},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%v(qmin: %v)", c.name, c.qmin), func(t *testing.T) {
+			opts := []Option{}
+			if !c.qmin {
+				opts = append(opts, DisableQNAMEMinimisation())
+			}
+			a, err := testFull(addrs, opts...).AResolve(c.name)
+			if err != nil {
+				t.Fatalf("err should be nil: %v", err)
+			}
+			if a.IP().String() != c.expected {
+				t.Fatalf("expected: %v, but got %v", c.expected, a.IP())
+			}
+			if c.relaxed != "" {
+				if next := broken.askedAfter(c.relaxed); next != c.name {
+					t.Fatalf("full name should be asked after %v, but got %v", c.relaxed, next)
+				}
+			}
+		})
+	}
+}
+
+func TestTooManyReferrals(t *testing.T) {
+	pcs, _, addrs := startAuthorities(t)
+	defer func() {
+		for _, pc := range pcs {
+			pc.Close()
+		}
+	}()
+
+	name := strings.Repeat("x.", maxReferrals+10) + "deep."
+	_, err := testFull(addrs, DisableQNAMEMinimisation()).Resolve(name, dns.A)
+	if err != errTooManyReferrals {
+		t.Fatalf("expected: %v, but got %v", errTooManyReferrals, err)
+	}
+}
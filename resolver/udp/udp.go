@@ -2,9 +2,11 @@ package udp
 
 import (
 	"bytes"
-	"fmt"
+	"encoding/binary"
 	"io"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/nna774/zorori/dns"
 	"github.com/nna774/zorori/resolver"
@@ -13,127 +15,290 @@ import (
 )
 
 var rootServers = []net.IP{
-	net.ParseIP("198.41.0.4"),
+	net.ParseIP("198.41.0.4"),     // a.root-servers.net
+	net.ParseIP("199.9.14.201"),   // b.root-servers.net
+	net.ParseIP("192.33.4.12"),    // c.root-servers.net
+	net.ParseIP("199.7.91.13"),    // d.root-servers.net
+	net.ParseIP("192.203.230.10"), // e.root-servers.net
+	net.ParseIP("192.5.5.241"),    // f.root-servers.net
+	net.ParseIP("192.112.36.4"),   // g.root-servers.net
+	net.ParseIP("198.97.190.53"),  // h.root-servers.net
+	net.ParseIP("192.36.148.17"),  // i.root-servers.net
+	net.ParseIP("192.58.128.30"),  // j.root-servers.net
+	net.ParseIP("193.0.14.129"),   // k.root-servers.net
+	net.ParseIP("199.7.83.42"),    // l.root-servers.net
+	net.ParseIP("202.12.27.33"),   // m.root-servers.net
 }
 
+const (
+	defaultTimeout  = 5 * time.Second
+	defaultAttempts = 2
+	// 0x20 を保存しないと判定したサーバーにも、この時間が経ったらまた試す。
+	noCaseFor = 10 * time.Minute
+)
+
+var (
+	errQuestionMismatch = errors.New("question mismatch")
+	errCaseMismatch     = errors.New("question case mismatch")
+)
+
 type udpResolver struct {
+	implements.Methods
 	stub     bool
-	resolver net.IP
+	servers  []net.IP
+	use0x20  bool
+	qmin     bool
+	timeout  time.Duration
+	attempts int
+	rotate   bool
+	udpSize  uint16
+	// addr はサーバーの接続先で、テストで差し替える。
+	addr func(server net.IP) string
+
+	mu   sync.Mutex
+	next int
+	// 0x20 を保存しないサーバーと、その判定の期限
+	noCase map[string]time.Time
 }
 
-// NewUDPStubResolver makes new stub resolver
-func NewUDPStubResolver(fullResolver net.IP) resolver.Resolver {
-	return &udpResolver{
-		stub:     true,
-		resolver: fullResolver,
+// Option configures udp resolver
+type Option func(*udpResolver)
+
+// Disable0x20 stops randomizing case of query names
+func Disable0x20() Option {
+	return func(t *udpResolver) {
+		t.use0x20 = false
+	}
+}
+
+// WithTimeout sets timeout of each query
+func WithTimeout(d time.Duration) Option {
+	return func(t *udpResolver) {
+		t.timeout = d
+	}
+}
+
+// WithAttempts sets how many times stub resolver asks each server
+func WithAttempts(n int) Option {
+	return func(t *udpResolver) {
+		t.attempts = n
+	}
+}
+
+// WithRotate makes stub resolver rotate servers for each query
+func WithRotate() Option {
+	return func(t *udpResolver) {
+		t.rotate = true
 	}
 }
 
+// WithEDNS0 advertises udp payload size by EDNS0
+func WithEDNS0(udpSize uint16) Option {
+	return func(t *udpResolver) {
+		t.udpSize = udpSize
+	}
+}
+
+// NewUDPStubResolver makes new stub resolver
+func NewUDPStubResolver(fullResolver net.IP, opts ...Option) resolver.Resolver {
+	return NewUDPStubResolverWithServers([]net.IP{fullResolver}, opts...)
+}
+
+// NewUDPStubResolverWithServers makes new stub resolver which asks servers in order
+func NewUDPStubResolverWithServers(servers []net.IP, opts ...Option) resolver.Resolver {
+	return newUDPResolver(&udpResolver{
+		stub:    true,
+		servers: servers,
+	}, opts)
+}
+
 // NewUDPFullResolver makes new full resolver
-func NewUDPFullResolver() resolver.Resolver {
-	return &udpResolver{
-		stub:     false,
-		resolver: rootServers[0],
+func NewUDPFullResolver(opts ...Option) resolver.Resolver {
+	return newUDPResolver(&udpResolver{
+		stub:    false,
+		servers: rootServers,
+		use0x20: true,
+		qmin:    true,
+	}, opts)
+}
+
+func newUDPResolver(t *udpResolver, opts []Option) *udpResolver {
+	t.Methods = implements.Methods{Resolve: t.Resolve}
+	t.noCase = map[string]time.Time{}
+	t.timeout = defaultTimeout
+	t.attempts = defaultAttempts
+	t.addr = port53
+	for _, opt := range opts {
+		opt(t)
 	}
+	return t
+}
+
+func port53(server net.IP) string {
+	return net.JoinHostPort(server.String(), "53")
 }
 
-func (t *udpResolver) AResolve(domain string) (dns.AResult, error) {
-	query := dns.NewQuery(domain, dns.A)
+func (t *udpResolver) ignoresCase(server net.IP) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Now().Before(t.noCase[server.String()])
+}
+
+func (t *udpResolver) markIgnoresCase(server net.IP) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.noCase[server.String()] = time.Now().Add(noCaseFor)
+}
+
+// exchange asks server, with 0x20 if enabled.
+func (t *udpResolver) exchange(server net.IP, name string, qt dns.QueryType) (dns.Answer, error) {
+	if !t.use0x20 || t.ignoresCase(server) {
+		return t.send(server, name, qt, false)
+	}
+	ans, err := t.send(server, dns.Randomize0x20(name), qt, true)
+	if err != errCaseMismatch {
+		return ans, err
+	}
+	// 大文字小文字を保存しないサーバーもいるので、素の名前で聞き直す。
+	ans, err = t.send(server, name, qt, false)
+	if err == nil {
+		// 偽装された答えで 0x20 を止められないよう、聞き直せてから覚える。
+		t.markIgnoresCase(server)
+	}
+	return ans, err
+}
+
+// send asks server, and retries over TCP if the answer is truncated.
+func (t *udpResolver) send(server net.IP, name string, qt dns.QueryType, matchCase bool) (dns.Answer, error) {
+	query := dns.NewQuery(name, qt)
+	size := 512
+	if t.udpSize > 0 {
+		query.SetEDNS0(t.udpSize)
+		size = int(t.udpSize)
+	}
 	var buf bytes.Buffer
-	n, err := io.Copy(&buf, &query)
+	_, err := io.Copy(&buf, &query)
 	if err != nil {
-		return implements.AFail(errors.Wrap(err, "bieao"))
+		return dns.Answer{}, errors.Wrap(err, "bieao")
 	}
-	p := buf.Bytes()
-	fmt.Printf("buf: %v(size: %v)\n", p, n)
-	srv := net.UDPAddr{
-		IP:   t.resolver,
-		Port: 53,
+	ans, err := t.sendUDP(server, query.Header.ID(), buf.Bytes(), size, name, qt, matchCase)
+	if err == nil && ans.Header.TC() {
+		// 切り詰められたので TCP で聞き直す。 see RFC 7766 5
+		ans, err = t.sendTCP(server, query.Header.ID(), buf.Bytes())
+		if err == nil {
+			err = checkQuestion(ans, name, qt, matchCase)
+		}
 	}
-	conn, err := net.DialUDP("udp", nil, &srv)
 	if err != nil {
-		return implements.AFail(errors.Wrap(err, "bieeeee"))
+		return dns.Answer{}, err
 	}
-	m, err := conn.Write(p)
+	return ans, nil
+}
+
+// sendUDP sends msg, replies whose question does not match are skipped until timeout.
+func (t *udpResolver) sendUDP(server net.IP, id uint16, msg []byte, size int, name string, qt dns.QueryType, matchCase bool) (dns.Answer, error) {
+	conn, err := net.Dial("udp", t.addr(server))
 	if err != nil {
-		return implements.AFail(errors.Wrap(err, "beee"))
+		return dns.Answer{}, errors.Wrap(err, "bieeeee")
 	}
-	body := make([]byte, 512)
-	r, err := conn.Read(body)
-	body = body[:r]
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(t.timeout))
+	_, err = conn.Write(msg)
 	if err != nil {
-		return implements.AFail(errors.Wrap(err, "peoe"))
+		return dns.Answer{}, errors.Wrap(err, "beee")
 	}
-	conn.Close()
-	fmt.Printf("body: %v(size: %v, len: %v, read: %v)\n", body, m, len(body), r)
-	fmt.Printf("bodys: %v\n", string(body))
-	ans, err := dns.ParseAnswer(body)
-	if err != nil {
-		return implements.AFail(errors.Wrap(err, "poe"))
-	}
-	ret := dns.AResult{}
-	searching := domain
-	for _, a := range ans.Answers {
-		// これだと順序が変わると引けなくなる。
-		if dns.Same(searching, a.Name) {
-			switch a.T {
-			case dns.A:
-				ip, _ := a.IP()
-				ret = dns.NewAResult(ip)
-				break
-			case dns.CNAME:
-				searching, _ = a.CNAMETO()
+	body := make([]byte, size)
+	caseMismatch := false
+	for {
+		r, err := conn.Read(body)
+		if err != nil {
+			if caseMismatch {
+				return dns.Answer{}, errCaseMismatch
 			}
+			return dns.Answer{}, errors.Wrap(err, "peoe")
 		}
-	}
-
-	if !t.stub {
-		// 再帰問い合わせをする。
-		if len(ans.Authorities) > 0 {
-
+		ans, err := dns.ParseAnswer(body[:r])
+		if err != nil || ans.Header.ID() != id {
+			// 壊れたものや関係ないパケットは読み捨てる。
+			continue
+		}
+		switch checkQuestion(ans, name, qt, matchCase) {
+		case nil:
+			return ans, nil
+		case errCaseMismatch:
+			// 偽装かもしれないので、期限までは正しい答えを待つ。
+			caseMismatch = true
 		}
 	}
-	return ret, nil
 }
 
-func (t *udpResolver) SVCBResolve() (dns.SVCBResult, error) {
-	query := dns.NewQuery("_dns.resolver.arpa", dns.SVCB)
-	var buf bytes.Buffer
-	n, err := io.Copy(&buf, &query)
+func (t *udpResolver) sendTCP(server net.IP, id uint16, msg []byte) (dns.Answer, error) {
+	conn, err := net.DialTimeout("tcp", t.addr(server), t.timeout)
 	if err != nil {
-		return implements.SVCBFail(errors.Wrap(err, "bieao"))
+		return dns.Answer{}, errors.Wrap(err, "tcp dial")
 	}
-	p := buf.Bytes()
-	fmt.Printf("buf: %v(size: %v)\n", p, n)
-	srv := net.UDPAddr{
-		IP:   t.resolver,
-		Port: 53,
-	}
-	conn, err := net.DialUDP("udp", nil, &srv)
-	if err != nil {
-		return implements.SVCBFail(errors.Wrap(err, "bieeeee"))
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(t.timeout))
+	l := make([]byte, 2)
+	binary.BigEndian.PutUint16(l, uint16(len(msg)))
+	if _, err := conn.Write(append(l, msg...)); err != nil {
+		return dns.Answer{}, errors.Wrap(err, "tcp write")
 	}
-	m, err := conn.Write(p)
-	if err != nil {
-		return implements.SVCBFail(errors.Wrap(err, "beee"))
+	if _, err := io.ReadFull(conn, l); err != nil {
+		return dns.Answer{}, errors.Wrap(err, "tcp read")
 	}
-	body := make([]byte, 512)
-	r, err := conn.Read(body)
-	body = body[:r]
-	if err != nil {
-		return implements.SVCBFail(errors.Wrap(err, "peoe"))
+	body := make([]byte, binary.BigEndian.Uint16(l))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return dns.Answer{}, errors.Wrap(err, "tcp read")
 	}
-	conn.Close()
-	fmt.Printf("body: %v(size: %v, len: %v, read: %v)\n", body, m, len(body), r)
-	fmt.Printf("bodys: %v\n", string(body))
 	ans, err := dns.ParseAnswer(body)
 	if err != nil {
-		return implements.SVCBFail(errors.Wrap(err, "poe"))
+		return dns.Answer{}, errors.Wrap(err, "tcp parse")
 	}
-	ret := dns.SVCBResult{
-		Target: ans.Answers[0].Name, // 大嘘 unused回避のため
+	if ans.Header.ID() != id {
+		return dns.Answer{}, errors.New("tcp id mismatch")
 	}
+	return ans, nil
+}
 
-	return ret, nil
+// stubResolve asks servers, rotating them if configured.
+func (t *udpResolver) stubResolve(name string, qt dns.QueryType) (dns.Answer, error) {
+	servers := t.servers
+	if t.rotate && len(servers) > 1 {
+		t.mu.Lock()
+		n := t.next % len(servers)
+		t.next++
+		t.mu.Unlock()
+		servers = append(append([]net.IP{}, servers[n:]...), servers[:n]...)
+	}
+	var ans dns.Answer
+	err := errNoServers
+	for i := 0; i < t.attempts; i++ {
+		for _, s := range servers {
+			ans, err = t.exchange(s, name, qt)
+			if err == nil {
+				if rcode := ans.Header.RCode(); rcode == dns.ServFail || rcode == dns.Refused {
+					// resolv.conf と同じく次のサーバーに聞く。
+					err = errors.Errorf("%v answered rcode %d", s, rcode)
+					continue
+				}
+				return ans, nil
+			}
+		}
+	}
+	return ans, err
+}
 
+func checkQuestion(ans dns.Answer, name string, qt dns.QueryType, matchCase bool) error {
+	if len(ans.Questions) != 1 {
+		return errQuestionMismatch
+	}
+	q := ans.Questions[0]
+	if q.Type() != qt || !dns.Same(q.Name(), name) {
+		return errQuestionMismatch
+	}
+	if matchCase && q.Name() != dns.Fqdn(name) {
+		return errCaseMismatch
+	}
+	return nil
 }
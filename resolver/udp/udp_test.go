@@ -0,0 +1,261 @@
+package udp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nna774/zorori/dns"
+)
+
+// listenUDP returns a fake server on loopback, reply makes datagrams to send back for a query.
+func listenUDP(t *testing.T, reply func(q []byte) [][]byte) net.PacketConn {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, b := range reply(append([]byte{}, buf[:n]...)) {
+				pc.WriteTo(b, addr)
+			}
+		}
+	}()
+	return pc
+}
+
+// fakeRR is a record which fake servers answer.
+type fakeRR struct {
+	name   string
+	t      dns.QueryType
+	ip     string
+	target string
+}
+
+func fakeA(name, ip string) fakeRR {
+	return fakeRR{name: name, t: dns.A, ip: ip}
+}
+
+func fakeNS(name, target string) fakeRR {
+	return fakeRR{name: name, t: dns.NS, target: target}
+}
+
+func packName(name string) []byte {
+	b := []byte{}
+	for _, l := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if l == "" {
+			continue
+		}
+		b = append(b, byte(len(l)))
+		b = append(b, l...)
+	}
+	return append(b, 0)
+}
+
+func (rr fakeRR) pack() []byte {
+	data := []byte(net.ParseIP(rr.ip).To4())
+	if rr.t == dns.NS {
+		data = packName(rr.target)
+	}
+	var fixed [10]byte
+	binary.BigEndian.PutUint16(fixed[0:], uint16(rr.t))
+	binary.BigEndian.PutUint16(fixed[2:], dns.IN)
+	binary.BigEndian.PutUint32(fixed[4:], 300)
+	binary.BigEndian.PutUint16(fixed[8:], uint16(len(data)))
+	b := append(packName(rr.name), fixed[:]...)
+	return append(b, data...)
+}
+
+// parseQuestion returns the question of query q, and where it ends.
+func parseQuestion(q []byte) (string, dns.QueryType, int) {
+	labels := []string{}
+	i := 12
+	for q[i] != 0 {
+		labels = append(labels, string(q[i+1:i+1+int(q[i])]))
+		i += int(q[i]) + 1
+	}
+	return strings.Join(labels, ".") + ".", dns.QueryType(binary.BigEndian.Uint16(q[i+1:])), i + 5
+}
+
+// packReply makes reply to q, the question is echoed as is.
+func packReply(q []byte, rcode int, answers, authorities, additionals []fakeRR) []byte {
+	_, _, end := parseQuestion(q)
+	b := append([]byte{}, q[:end]...)
+	// QR と AA を立てる。
+	binary.BigEndian.PutUint16(b[2:], 0x8400|uint16(rcode))
+	sections := [][]fakeRR{answers, authorities, additionals}
+	for i, rrs := range sections {
+		binary.BigEndian.PutUint16(b[6+2*i:], uint16(len(rrs)))
+	}
+	for _, rrs := range sections {
+		for _, rr := range rrs {
+			b = append(b, rr.pack()...)
+		}
+	}
+	return b
+}
+
+// swapCase flips case of the first letter of the question name.
+func swapCase(b []byte) []byte {
+	b = append([]byte{}, b...)
+	b[13] ^= 0x20
+	return b
+}
+
+// listenTCP serves on the same port as pc, reply makes a message to send back for a query.
+func listenTCP(t *testing.T, pc net.PacketConn, reply func(q []byte) []byte) net.Listener {
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				n := make([]byte, 2)
+				if _, err := io.ReadFull(conn, n); err != nil {
+					return
+				}
+				q := make([]byte, binary.BigEndian.Uint16(n))
+				if _, err := io.ReadFull(conn, q); err != nil {
+					return
+				}
+				b := reply(q)
+				binary.BigEndian.PutUint16(n, uint16(len(b)))
+				conn.Write(append(n, b...))
+			}()
+		}
+	}()
+	return l
+}
+
+func testServer(pc net.PacketConn) *udpResolver {
+	r := NewUDPFullResolver(DisableQNAMEMinimisation()).(*udpResolver)
+	r.timeout = 200 * time.Millisecond
+	r.addr = func(net.IP) string { return pc.LocalAddr().String() }
+	return r
+}
+
+func TestStrayDatagram(t *testing.T) {
+	pc := listenUDP(t, func(q []byte) [][]byte {
+		name, _, _ := parseQuestion(q)
+		valid := packReply(q, dns.NoError, []fakeRR{fakeA(name, "192.0.2.1")}, nil, nil)
+		other := append([]byte{}, valid...)
+		other[0]++
+		spoofed := packReply(q, dns.NoError, []fakeRR{fakeA(name, "192.0.2.66")}, nil, nil)
+		// 壊れたもの、ID 違い、大文字小文字違いを先に送りつける。
+		return [][]byte{{0, 1, 2}, other, swapCase(spoofed), valid}
+	})
+	defer pc.Close()
+
+	r := testServer(pc)
+	a, err := r.AResolve("www.example.")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if !a.IP().Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("unexpected addr: %v", a.IP())
+	}
+	if r.ignoresCase(r.servers[0]) {
+		t.Fatalf("spoofed reply should not disable 0x20")
+	}
+}
+
+func TestIgnoresCase(t *testing.T) {
+	pc := listenUDP(t, func(q []byte) [][]byte {
+		// 大文字小文字を保存しないサーバー
+		_, _, end := parseQuestion(q)
+		copy(q[12:end], strings.ToLower(string(q[12:end])))
+		name, _, _ := parseQuestion(q)
+		return [][]byte{packReply(q, dns.NoError, []fakeRR{fakeA(name, "192.0.2.1")}, nil, nil)}
+	})
+	defer pc.Close()
+
+	r := testServer(pc)
+	server := r.servers[0]
+	if _, err := r.AResolve("www.example."); err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if !r.ignoresCase(server) {
+		t.Fatalf("server should be marked after plain retry")
+	}
+	r.noCase[server.String()] = time.Now().Add(-time.Second)
+	if r.ignoresCase(server) {
+		t.Fatalf("mark should expire")
+	}
+}
+
+func TestTruncatedRetriesTCP(t *testing.T) {
+	answers := func(name string) []fakeRR {
+		rrs := []fakeRR{}
+		for i := 0; i < 40; i++ {
+			rrs = append(rrs, fakeA(name, fmt.Sprintf("192.0.2.%d", i)))
+		}
+		return rrs
+	}
+	pc := listenUDP(t, func(q []byte) [][]byte {
+		b := packReply(q, dns.NoError, nil, nil, nil)
+		// TC を立てる。
+		b[2] |= 0x02
+		return [][]byte{b}
+	})
+	defer pc.Close()
+	l := listenTCP(t, pc, func(q []byte) []byte {
+		name, _, _ := parseQuestion(q)
+		return packReply(q, dns.NoError, answers(name), nil, nil)
+	})
+	defer l.Close()
+
+	r := NewUDPStubResolver(net.ParseIP("192.0.2.53"), Disable0x20(), WithTimeout(time.Second)).(*udpResolver)
+	r.addr = func(net.IP) string { return pc.LocalAddr().String() }
+	ans, err := r.Resolve("big.example.", dns.A)
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if ans.Header.TC() || len(ans.Answers) != 40 {
+		t.Fatalf("should be retried over TCP: %v", ans.Header)
+	}
+}
+
+func TestStubFailover(t *testing.T) {
+	servfail := listenUDP(t, func(q []byte) [][]byte {
+		return [][]byte{packReply(q, dns.ServFail, nil, nil, nil)}
+	})
+	defer servfail.Close()
+	ok := listenUDP(t, func(q []byte) [][]byte {
+		name, _, _ := parseQuestion(q)
+		return [][]byte{packReply(q, dns.NoError, []fakeRR{fakeA(name, "192.0.2.2")}, nil, nil)}
+	})
+	defer ok.Close()
+
+	first, second := net.ParseIP("192.0.2.53"), net.ParseIP("192.0.2.54")
+	r := NewUDPStubResolverWithServers([]net.IP{first, second},
+		Disable0x20(), WithTimeout(time.Second), WithAttempts(1)).(*udpResolver)
+	// 同じ loopback の別のポートに振り分ける。
+	r.addr = func(server net.IP) string {
+		if server.Equal(first) {
+			return servfail.LocalAddr().String()
+		}
+		return ok.LocalAddr().String()
+	}
+	a, err := r.AResolve("www.example.")
+	if err != nil {
+		t.Fatalf("err should be nil: %v", err)
+	}
+	if !a.IP().Equal(net.ParseIP("192.0.2.2")) {
+		t.Fatalf("SERVFAIL should be skipped: %v", a.IPs())
+	}
+}